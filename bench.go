@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func runBench(ctx context.Context, args []string) (err error) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	targetNames := flags.String("targets", "", "comma separated target names, default all targets")
+	strategyNames := flags.String("strategies", "", "comma separated strategy names, default all strategies")
+	iterations := flags.Int("iterations", 0, "measured iterations per strategy, run for -duration when 0")
+	duration := flags.Duration("duration", 10*time.Second, "measured duration per strategy")
+	warmup := flags.Int("warmup", 3, "warm-up iterations per strategy")
+	isolations := flags.String("isolation", os.Getenv("TX_ISOLATION"), "comma separated transaction isolation levels: default, read-uncommitted, read-committed, repeatable-read, snapshot (repeatable-read, which is snapshot isolation on InnoDB and PostgreSQL), serializable")
+	readOnlys := flags.String("read-only", os.Getenv("TX_READ_ONLY"), "comma separated transaction read-only flags")
+	clientCounts := flags.String("clients", "", "comma separated concurrent client counts to sweep in load mode, e.g. 1,2,4,8,16")
+	poolSize := flags.Int("pool", 0, "max open connections per target in load mode, default keep driver setting")
+	limitValues := flags.String("limits", forumdb.DefaultLimit.String(), "comma separated FORUMSxTHREADSxPOSTS limits to sweep")
+	outDir := flags.String("out", "results", "directory to write JSON and CSV result files")
+	decoderNames := flags.String("decoders", forumdb.DefaultDecoder.Name, "comma separated JSON decoders to sweep: strict, std, fast")
+	withExplain := flags.Bool("explain", true, "capture plan of every statement issued by each strategy")
+	withServerStats := flags.Bool("server-stats", true, "capture server statement counters, pg_stat_statements or performance_schema")
+	rtts := flags.String("rtt", "", "comma separated round trip times to sweep through a local latency proxy, e.g. 100us,1ms,5ms,20ms")
+	jitter := flags.Duration("jitter", 0, "max random deviation of round trip time through the latency proxy")
+	bandwidth := flags.Int64("bandwidth", 0, "bytes per second of each direction through the latency proxy, 0 means unlimited")
+	pageDepths := flags.String("pages", "", "comma separated page depths counted from 0 to sweep, each strategy loads page N of forums instead of the first forums, e.g. 0,10,100,1000")
+	pageModes := flags.String("page-modes", "keyset,offset", "comma separated page modes of -pages: keyset, offset")
+	workloadName := flags.String("workload", string(workloadForums), "forums: first forums or pages, latest: forums by latest activity, lookup: one forum by ID per iteration, search: forums with threads and posts matching a term per iteration")
+	lookupNames := flags.String("lookups", string(lookupRandom), "comma separated distributions to sweep of forums in lookup workload or terms in search workload: fixed, random, zipf")
+	termNames := flags.String("terms", "", "comma separated terms of search workload, default words drawn from the lorem vocabulary")
+	aggregateNames := flags.String("aggregates", "", "comma separated ways to also load thread count of forums and post count of threads to sweep: sub-query, app-query, counter, no count when empty")
+	writers := flags.Int("writers", 0, "concurrent writers inserting, editing and deleting posts and threads while every case reads, 0 means no write load")
+	writeRate := flags.Float64("write-rate", 0, "total write operations per second of all writers, 0 means as fast as possible")
+	writeMixValue := flags.String("write-mix", defaultWriteMix, "comma separated KIND=WEIGHT of -writers, kinds: post, thread, edit, delete")
+	withProfiles := flags.Bool("profile", false, "capture CPU, heap and allocation profiles of every run case into <out>/<timestamp>-profiles")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+
+	targets := filterTargets(loadAllTargets(), splitNames(*targetNames))
+	if len(targets) < 1 {
+		return errors.New("no target selected, set MYSQL_URL or PGSQL_URL")
+	}
+	txOpts, err := parseTxOptions(*isolations, *readOnlys)
+	if err != nil {
+		return
+	}
+	clients, err := parseInts(*clientCounts)
+	if err != nil {
+		return
+	}
+	limits, err := parseLimits(*limitValues)
+	if err != nil {
+		return
+	}
+	networks, err := parseNetworks(*rtts, *jitter, *bandwidth)
+	if err != nil {
+		return
+	}
+	decoders, err := parseDecoders(*decoderNames)
+	if err != nil {
+		return
+	}
+	pages, err := parsePages(*pageDepths, *pageModes)
+	if err != nil {
+		return
+	}
+	workload, err := parseWorkload(*workloadName)
+	if err != nil {
+		return
+	}
+	if len(pages) > 0 && workload != workloadForums {
+		return fmt.Errorf("-pages is not supported by %s workload", workload)
+	}
+	lookups := []lookupType(nil)
+	if workload.picks() {
+		if lookups, err = parseLookups(*lookupNames); err != nil {
+			return
+		}
+	}
+	aggregates, err := parseAggregates(*aggregateNames)
+	if err != nil {
+		return
+	}
+	if *writers < 0 || *writeRate < 0 {
+		return errors.New("-writers and -write-rate should not be negative")
+	}
+	if *writers > 0 && len(clients) < 1 {
+		return errors.New("-writers needs -clients, sequential mode reads all iterations in one transaction that doesn't see the writes")
+	}
+	writeMix, err := parseWriteMix(*writeMixValue)
+	if err != nil {
+		return
+	}
+	terms := []string(nil)
+	if workload == workloadSearch {
+		if terms = splitNames(*termNames); len(terms) < 1 {
+			terms = drawSearchTerms(searchTermCount)
+		}
+		consoleLogger.Logf("search terms: %s\n", strings.Join(terms, ","))
+	} else if *termNames != "" {
+		return fmt.Errorf("-terms is not supported by %s workload", workload)
+	}
+	timestamp := time.Now()
+	profileDir := ""
+	if *withProfiles {
+		profileDir = filepath.Join(*outDir, timestamp.Format("20060102-150405")+"-profiles")
+	}
+	options := benchOptionsType{
+		Strategies:  splitNames(*strategyNames),
+		TxOptions:   txOpts,
+		Limits:      limits,
+		Decoders:    decoders,
+		Clients:     clients,
+		Workload:    workload,
+		Pages:       pages,
+		Lookups:     lookups,
+		Terms:       terms,
+		Aggregates:  aggregates,
+		Pool:        *poolSize,
+		Explain:     *withExplain,
+		ServerStats: *withServerStats,
+		Config: runConfigType{
+			Iterations: *iterations,
+			Duration:   *duration,
+			Warmup:     *warmup,
+			ProfileDir: profileDir,
+			Write: writeConfigType{
+				Writers: *writers,
+				Rate:    *writeRate,
+				Mix:     writeMix,
+			},
+		},
+	}
+
+	results := []runResultType{}
+	for _, baseTarget := range targets {
+		for _, network := range networks {
+			target := *baseTarget
+			target.Network = network
+			targetResults, errBench := benchTarget(ctx, &target, options)
+			if errBench != nil {
+				return errBench
+			}
+			results = append(results, targetResults...)
+		}
+	}
+
+	showRunResults(os.Stdout, results)
+
+	file := resultFileType{Timestamp: timestamp}
+	for _, result := range results {
+		file.Records = append(file.Records, newResultRecord(result, timestamp))
+	}
+	paths, err := writeResultFiles(*outDir, file)
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		consoleLogger.Logf("result written to %s\n", path)
+	}
+	return
+}
+
+// benchOptionsType is the bench settings applied to every target
+type benchOptionsType struct {
+	// Strategies are names of strategies to run, empty means all
+	Strategies []string
+	TxOptions  []txOptionType
+	Limits     []forumdb.Limit
+	Decoders   []forumdb.Decoder
+	Clients    []int
+	Workload   workloadType
+	Pages      []pageType
+	// Lookups are forum distributions of lookup workload or term distributions of search workload
+	Lookups []lookupType
+	// Terms are search terms of search workload
+	Terms []string
+	// Aggregates are ways to load counts, nil means no count
+	Aggregates  []forumdb.Aggregate
+	Pool        int
+	Explain     bool
+	ServerStats bool
+	Config      runConfigType
+}
+
+// benchTarget open target, run every case of options and close target
+func benchTarget(ctx context.Context, target *targetType, options benchOptionsType) (results []runResultType, err error) {
+	if err = target.Open(ctx); err != nil {
+		return
+	}
+	defer func() {
+		if errClose := target.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+
+	consoleLogger.Logf("target %s\n", target.Label())
+	if target.Shape, err = showTargetDataCount(ctx, target, consoleLogger); err != nil {
+		return
+	}
+	if options.ServerStats {
+		if _, errStats := readServerStats(ctx, target.DB, target.Dialect); errStats != nil {
+			consoleLogger.Logf("server stats disabled: %v\n", errStats)
+		} else {
+			target.ServerStats = true
+		}
+	}
+	if options.Pool > 0 {
+		for _, db := range target.dbs() {
+			db.SetMaxOpenConns(options.Pool)
+			db.SetMaxIdleConns(options.Pool)
+		}
+	}
+
+	strategies := filterStrategies(options.Workload.strategies(target.Dialect), options.Strategies)
+	if err = warnMissingIndexes(ctx, target, options.Workload, consoleLogger); err != nil {
+		return
+	}
+	if options.Workload == workloadLookup {
+		if target.ForumIDs, err = loadForumIDs(ctx, target); err != nil {
+			return
+		}
+		if len(target.ForumIDs) < 1 {
+			return nil, fmt.Errorf("%s has no forum to look up", target.Label())
+		}
+	}
+	if options.Workload == workloadSearch {
+		target.SearchTerms = options.Terms
+	}
+	if err = checkCounters(ctx, target, options.Aggregates, options.Config.Write.Writers); err != nil {
+		return
+	}
+	if options.Config.Write.Writers > 0 {
+		if target.Writers, err = startWriteProcess(ctx, target, options.Config.Write); err != nil {
+			return
+		}
+		defer func() {
+			if errClose := target.Writers.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+			target.Writers = nil
+		}()
+	}
+	// plans don't change with transaction option or clients, explain once per strategy, limit and page of workload
+	plans := map[string][]planType{}
+	for _, c := range runCases(strategies, options.TxOptions, options.Limits, options.Decoders, options.Clients, options.Pages, options.Lookups, options.Aggregates) {
+		c.Workload = options.Workload
+		if c.Page, err = c.Page.resolve(ctx, target, c.Limit); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", target.Label(), c, err)
+		}
+		if c.Clients > 0 && options.Pool < 1 {
+			// keep idle connections so clients don't reconnect every iteration
+			for _, db := range target.dbs() {
+				db.SetMaxIdleConns(c.Clients)
+			}
+		}
+		result, errRun := runCase(ctx, target, c, options.Config)
+		if errRun != nil {
+			return nil, fmt.Errorf("%s %s: %v", target.Label(), c, errRun)
+		}
+		if options.Explain {
+			planKey := c.Strategy.Name + " " + c.Limit.String() + " " + c.Page.String() + " " + string(c.Aggregate)
+			if _, ok := plans[planKey]; !ok {
+				if plans[planKey], err = explainCase(ctx, target, c); err != nil {
+					return nil, fmt.Errorf("%s %s explain: %v", target.Label(), c, err)
+				}
+			}
+			result.Plans = plans[planKey]
+		}
+		results = append(results, result)
+	}
+	return
+}
+
+// showTargetDataCount show and return exact table row counts of target
+func showTargetDataCount(ctx context.Context, target *targetType, logger loggerType) (shape dataShapeType, err error) {
+	tx, err := target.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	if shape, err = selectDataShape(ctx, tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	logger.Logf("Total %+v\n", shape)
+	err = tx.Commit()
+	return
+}
+
+func showRunResults(output io.Writer, results []runResultType) {
+	writer := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "TARGET\tSTRATEGY\tTX\tLIMIT\tPAGE\tLOOKUP\tAGGREGATE\tDECODER\tCLIENTS\tITERATIONS\tP50\tP90\tP99\tMAX\tOPS/S\tPOOL-WAIT/OP")
+	for _, result := range results {
+		latency := result.Latency()
+		clients := "-"
+		if result.Clients > 0 {
+			clients = strconv.Itoa(result.Clients)
+		}
+		page := "-"
+		if result.Page.Mode != forumdb.PageNone {
+			page = result.Page.String()
+		}
+		lookup := "-"
+		if result.Lookup != lookupNone {
+			lookup = string(result.Lookup)
+		}
+		aggregate := "-"
+		if result.Aggregate != forumdb.AggregateNone {
+			aggregate = string(result.Aggregate)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%v\t%v\t%v\t%v\t%.2f\t%v\n",
+			result.Target.Label(),
+			result.Strategy.Name,
+			result.TxOption,
+			result.Limit,
+			page,
+			lookup,
+			aggregate,
+			result.Decoder.Name,
+			clients,
+			len(result.Samples),
+			latency.P50,
+			latency.P90,
+			latency.P99,
+			latency.Max,
+			result.Throughput(),
+			result.PoolWaitPerOp(),
+		)
+	}
+	writer.Flush()
+
+	header := false
+	for _, result := range results {
+		stats := result.ServerStatsPerOp()
+		if stats == nil {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(output, "\nserver stats per op")
+			header = true
+		}
+		fmt.Fprintf(output, "%s %s: %s\n", result.Target.Label(), result.runCaseType, stats.String(result.Target.Dialect))
+	}
+
+	fmt.Fprintln(output, "\nphases per op")
+	for _, result := range results {
+		fmt.Fprintf(output, "%s %s: %s\n", result.Target.Label(), result.runCaseType, result.PhasesPerOp())
+	}
+
+	fmt.Fprintln(output, "\nmemory per op")
+	for _, result := range results {
+		fmt.Fprintf(output, "%s %s: allocs=%d bytes=%d gc=%.3f gc-pause=%v\n",
+			result.Target.Label(),
+			result.runCaseType,
+			result.AllocsPerOp(),
+			result.BytesPerOp(),
+			result.GCCyclesPerOp(),
+			result.GCPausePerOp(),
+		)
+	}
+
+	header = false
+	for _, result := range results {
+		if result.Writes == nil {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(output, "\nwrite load")
+			header = true
+		}
+		fmt.Fprintf(output, "%s %s: %s\n", result.Target.Label(), result.runCaseType, result.Writes)
+	}
+
+	header = false
+	for _, result := range results {
+		profiles := result.Profiles
+		if profiles.CPU == "" {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(output, "\nprofiles")
+			header = true
+		}
+		fmt.Fprintf(output, "%s %s:\n", result.Target.Label(), result.runCaseType)
+		fmt.Fprintf(output, "\tcpu:    go tool pprof %s\n", profiles.CPU)
+		fmt.Fprintf(output, "\theap:   go tool pprof %s\n", profiles.Heap)
+		fmt.Fprintf(output, "\tallocs: go tool pprof -sample_index=alloc_space -base %s %s\n", profiles.AllocsBase, profiles.Allocs)
+	}
+}
+
+// splitNames split comma separated names, return nil for empty string
+func splitNames(names string) (result []string) {
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return
+}
+
+// parseLimits parse comma separated FORUMSxTHREADSxPOSTS, e.g. 10x10x10,100x10x10
+func parseLimits(values string) (limits []forumdb.Limit, err error) {
+	for _, value := range splitNames(values) {
+		limit := forumdb.Limit{}
+		if _, err = fmt.Sscanf(value, "%dx%dx%d", &limit.Forums, &limit.Threads, &limit.Posts); err != nil {
+			return nil, fmt.Errorf("invalid limit %q: %v", value, err)
+		}
+		if limit.Forums < 1 || limit.Threads < 1 || limit.Posts < 1 {
+			return nil, fmt.Errorf("invalid limit %q, should be positive", value)
+		}
+		limits = append(limits, limit)
+	}
+	return
+}
+
+// parseDecoders return decoders of comma separated names, default decoder for empty names
+func parseDecoders(names string) (result []forumdb.Decoder, err error) {
+	for _, name := range splitNames(names) {
+		decoder, err := forumdb.DecoderByName(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, decoder)
+	}
+	if len(result) < 1 {
+		result = []forumdb.Decoder{forumdb.DefaultDecoder}
+	}
+	return
+}
+
+// parsePages return pages of every mode at every depth,
+// comma separated depths are counted from 0, nil for empty depths
+func parsePages(depths string, modes string) (result []pageType, err error) {
+	pageModes := []forumdb.PageMode{}
+	for _, name := range splitNames(modes) {
+		mode, err := forumdb.PageModeByName(name)
+		if err != nil {
+			return nil, err
+		}
+		pageModes = append(pageModes, mode)
+	}
+	for _, value := range splitNames(depths) {
+		depth, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		if depth < 0 {
+			return nil, fmt.Errorf("invalid page depth %d, should not be negative", depth)
+		}
+		for _, mode := range pageModes {
+			result = append(result, pageType{Page: forumdb.Page{Mode: mode}, Depth: depth})
+		}
+	}
+	if len(result) < 1 && len(splitNames(depths)) > 0 {
+		return nil, errors.New("no page mode selected")
+	}
+	return
+}
+
+// parseAggregates parse comma separated aggregate names, return nil for empty string
+func parseAggregates(names string) (result []forumdb.Aggregate, err error) {
+	for _, name := range splitNames(names) {
+		aggregate, err := forumdb.AggregateByName(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, aggregate)
+	}
+	return
+}
+
+// parseInts parse comma separated positive integers
+func parseInts(values string) (result []int, err error) {
+	for _, value := range splitNames(values) {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		if i < 1 {
+			return nil, fmt.Errorf("invalid value %d, should be positive", i)
+		}
+		result = append(result, i)
+	}
+	return
+}
+
+func filterTargets(targets []*targetType, names []string) (result []*targetType) {
+	if len(names) < 1 {
+		return targets
+	}
+	for _, target := range targets {
+		if containsName(names, target.Name) {
+			result = append(result, target)
+		}
+	}
+	return
+}
+
+func filterStrategies(strategies []forumdb.Strategy, names []string) (result []forumdb.Strategy) {
+	if len(names) < 1 {
+		return strategies
+	}
+	for _, strategy := range strategies {
+		if containsName(names, strategy.Name) {
+			result = append(result, strategy)
+		}
+	}
+	return
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// resultKeyType identify the same run in different result files
+type resultKeyType struct {
+	Target    string
+	Network   networkType
+	Strategy  string
+	Isolation string
+	ReadOnly  bool
+	Clients   int
+	Shape     dataShapeType
+	Limit     forumdb.Limit
+	Decoder   string
+	PageMode  forumdb.PageMode
+	PageDepth int
+	Lookup    string
+	Workload  string
+	Aggregate string
+	// Terms are space separated search terms, empty for other workloads
+	Terms string
+	// Writers, WriteRate and WriteMix are the write load running alongside, zero without write load
+	Writers   int
+	WriteRate float64
+	WriteMix  string
+}
+
+func newResultKey(record resultRecordType) resultKeyType {
+	key := resultKeyType{
+		Target:    record.Target,
+		Network:   record.Network,
+		Strategy:  record.Strategy,
+		Isolation: record.Isolation,
+		ReadOnly:  record.ReadOnly,
+		Clients:   record.Clients,
+		Shape:     record.Shape,
+		Limit:     record.Limit,
+		Decoder:   record.Decoder,
+		PageMode:  record.PageMode,
+		PageDepth: record.PageDepth,
+		Lookup:    record.Lookup,
+		Workload:  record.Workload,
+		Aggregate: record.Aggregate,
+		Terms:     strings.Join(record.Terms, " "),
+	}
+	if record.Writes != nil {
+		key.Writers = record.Writes.Writers
+		key.WriteRate = record.Writes.Rate
+		key.WriteMix = record.Writes.Mix
+	}
+	// results written before decoders were selectable used the default decoder
+	if key.Decoder == "" {
+		key.Decoder = forumdb.DefaultDecoder.Name
+	}
+	if key.Workload == "" {
+		key.Workload = string(workloadForums)
+	}
+	return key
+}
+
+func (t resultKeyType) String() string {
+	txOpt := txOptionType{Isolation: t.Isolation, ReadOnly: t.ReadOnly}
+	target := targetType{Name: t.Target, Network: t.Network}
+	result := fmt.Sprintf("%s %s %s clients=%d limit=%d/%d/%d decoder=%s", target.Label(), t.Strategy, txOpt, t.Clients, t.Limit.Forums, t.Limit.Threads, t.Limit.Posts, t.Decoder)
+	if t.PageMode != forumdb.PageNone {
+		result += fmt.Sprintf(" page=%s:%d", t.PageMode, t.PageDepth)
+	}
+	if t.Lookup != "" {
+		result += " lookup=" + t.Lookup
+	}
+	if t.Workload != string(workloadForums) {
+		result += " workload=" + t.Workload
+	}
+	if t.Aggregate != "" {
+		result += " aggregate=" + t.Aggregate
+	}
+	if t.Terms != "" {
+		result += " terms=" + termsLabel(strings.Fields(t.Terms))
+	}
+	if t.Writers > 0 {
+		result += fmt.Sprintf(" writers=%d rate=%g mix=%s", t.Writers, t.WriteRate, t.WriteMix)
+	}
+	return result
+}
+
+// comparisonType is the latency change of one run between two result files
+type comparisonType struct {
+	Key       resultKeyType
+	OldMedian float64
+	NewMedian float64
+	// Delta is the relative change of median latency, 0.1 means 10% slower
+	Delta   float64
+	DeltaLo float64
+	DeltaHi float64
+	PValue  float64
+}
+
+// Significant return true when p-value is below alpha
+func (t comparisonType) Significant(alpha float64) bool {
+	return t.PValue < alpha
+}
+
+// Regressed return true when latency significantly increased more than threshold
+func (t comparisonType) Regressed(alpha float64, threshold float64) bool {
+	return t.Significant(alpha) && t.Delta > threshold
+}
+
+// compareResults match records of old and new by key and compare their latency samples,
+// return comparisons in the order of new records and keys found in only one file
+func compareResults(oldFile resultFileType, newFile resultFileType, confidence float64) (comparisons []comparisonType, unmatched []resultKeyType) {
+	oldRecords := map[resultKeyType]resultRecordType{}
+	for _, record := range oldFile.Records {
+		oldRecords[newResultKey(record)] = record
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	matched := map[resultKeyType]bool{}
+	for _, newRecord := range newFile.Records {
+		key := newResultKey(newRecord)
+		oldRecord, ok := oldRecords[key]
+		if !ok {
+			unmatched = append(unmatched, key)
+			continue
+		}
+		matched[key] = true
+
+		oldSamples := recordSamples(oldRecord)
+		newSamples := recordSamples(newRecord)
+		comparison := comparisonType{
+			Key:       key,
+			OldMedian: median(oldSamples),
+			NewMedian: median(newSamples),
+			PValue:    mannWhitneyU(oldSamples, newSamples),
+		}
+		if comparison.OldMedian > 0 {
+			comparison.Delta = comparison.NewMedian/comparison.OldMedian - 1
+		}
+		comparison.DeltaLo, comparison.DeltaHi = bootstrapMedianDelta(oldSamples, newSamples, 1000, confidence, rnd)
+		comparisons = append(comparisons, comparison)
+	}
+
+	for _, record := range oldFile.Records {
+		if key := newResultKey(record); !matched[key] {
+			unmatched = append(unmatched, key)
+		}
+	}
+	return
+}
+
+func recordSamples(record resultRecordType) []float64 {
+	samples := make([]float64, 0, len(record.Samples))
+	for _, sample := range record.Samples {
+		samples = append(samples, float64(sample))
+	}
+	return samples
+}
+
+func runCompare(ctx context.Context, args []string) (err error) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	threshold := flags.Float64("threshold", 5, "fail when median latency regresses more than this percentage")
+	alpha := flags.Float64("alpha", 0.05, "significance level of Mann-Whitney U test")
+	confidence := flags.Float64("confidence", 0.95, "confidence level of bootstrap interval")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s compare [flags] <old.json> <new.json>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("compare needs two result files")
+	}
+
+	oldFile, err := readResultFile(flags.Arg(0))
+	if err != nil {
+		return
+	}
+	newFile, err := readResultFile(flags.Arg(1))
+	if err != nil {
+		return
+	}
+
+	comparisons, unmatched := compareResults(oldFile, newFile, *confidence)
+	showComparisons(os.Stdout, comparisons, *alpha, *confidence)
+	for _, key := range unmatched {
+		consoleLogger.Logf("unmatched %s\n", key)
+	}
+
+	regressions := 0
+	for _, comparison := range comparisons {
+		if comparison.Regressed(*alpha, *threshold/100) {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%d runs regressed more than %v%%", regressions, *threshold)
+	}
+	return
+}
+
+func showComparisons(output io.Writer, comparisons []comparisonType, alpha float64, confidence float64) {
+	writer := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "RUN\tOLD-P50\tNEW-P50\tDELTA\tCI%.0f\tP-VALUE\n", confidence*100)
+	for _, comparison := range comparisons {
+		delta := "~"
+		if comparison.Significant(alpha) {
+			delta = fmt.Sprintf("%+.2f%%", comparison.Delta*100)
+		}
+		fmt.Fprintf(writer, "%s\t%v\t%v\t%s\t[%+.2f%%, %+.2f%%]\t%.4f\n",
+			comparison.Key,
+			time.Duration(comparison.OldMedian),
+			time.Duration(comparison.NewMedian),
+			delta,
+			comparison.DeltaLo*100,
+			comparison.DeltaHi*100,
+			comparison.PValue,
+		)
+	}
+	writer.Flush()
+}
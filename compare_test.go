@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_compareResults(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	newRecord := func(strategy string, base time.Duration) resultRecordType {
+		record := resultRecordType{
+			Target:    "pgsql",
+			Strategy:  strategy,
+			Isolation: defaultTxOption.Isolation,
+			Limit:     forumdb.DefaultLimit,
+		}
+		for i := 0; i < 50; i++ {
+			record.Samples = append(record.Samples, int64(base+time.Duration(i%5)*time.Microsecond))
+		}
+		return record
+	}
+
+	oldFile := resultFileType{Records: []resultRecordType{
+		newRecord("app-query", 10*time.Millisecond),
+		newRecord("sub-query", 5*time.Millisecond),
+		newRecord("lateral", 5*time.Millisecond),
+	}}
+	newFile := resultFileType{Records: []resultRecordType{
+		newRecord("app-query", 10*time.Millisecond),
+		newRecord("sub-query", 6*time.Millisecond),
+		newRecord("flat-join", 5*time.Millisecond),
+	}}
+
+	comparisons, unmatched := compareResults(oldFile, newFile, 0.95)
+	require.Len(comparisons, 2)
+	require.Len(unmatched, 2)
+	assert.Equal("flat-join", unmatched[0].Strategy)
+	assert.Equal("lateral", unmatched[1].Strategy)
+
+	assert.Equal("app-query", comparisons[0].Key.Strategy)
+	assert.False(comparisons[0].Significant(0.05))
+	assert.False(comparisons[0].Regressed(0.05, 0.05))
+
+	assert.Equal("sub-query", comparisons[1].Key.Strategy)
+	assert.InDelta(0.2, comparisons[1].Delta, 0.001)
+	assert.True(comparisons[1].Regressed(0.05, 0.05))
+	assert.False(comparisons[1].Regressed(0.05, 0.25))
+}
+
+func Test_newResultKeyTerms(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	record := resultRecordType{Target: "mysql", Strategy: "sub-query", Workload: string(workloadSearch), Terms: []string{"ipsum", "lorem"}}
+	key := newResultKey(record)
+	assert.Equal("ipsum lorem", key.Terms)
+	assert.Contains(key.String(), " terms=2:")
+
+	record.Terms = []string{"dolor", "lorem"}
+	assert.NotEqual(key, newResultKey(record))
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// consistency dataset is small enough that no strategy limit truncates it,
+// so every tree read must equal the whole dataset at one committed generation
+const (
+	consistencyForumCount  = 3
+	consistencyThreadCount = 5
+	consistencyPostCount   = 5
+)
+
+// consistencyTreeType map forumID -> threadID -> postIDs
+type consistencyTreeType map[string]map[string][]string
+
+func newConsistencyTree(data []forumdb.Forum) consistencyTreeType {
+	tree := consistencyTreeType{}
+	for _, forum := range data {
+		threads := map[string][]string{}
+		for _, thread := range forum.Threads {
+			posts := []string{}
+			for _, post := range thread.Posts {
+				posts = append(posts, post.PostID)
+			}
+			threads[thread.ThreadID] = posts
+		}
+		tree[forum.ForumID] = threads
+	}
+	return tree
+}
+
+func (t consistencyTreeType) clone() consistencyTreeType {
+	tree := consistencyTreeType{}
+	for forumID, threads := range t {
+		tree[forumID] = map[string][]string{}
+		for threadID, posts := range threads {
+			tree[forumID][threadID] = append([]string{}, posts...)
+		}
+	}
+	return tree
+}
+
+// fingerprint return an order independent representation of tree
+func (t consistencyTreeType) fingerprint() string {
+	buffer := strings.Builder{}
+	for _, forumID := range sortedKeys(t) {
+		buffer.WriteString("f:" + forumID + "\n")
+		threads := t[forumID]
+		threadIDs := make([]string, 0, len(threads))
+		for threadID := range threads {
+			threadIDs = append(threadIDs, threadID)
+		}
+		sort.Strings(threadIDs)
+		for _, threadID := range threadIDs {
+			posts := append([]string{}, threads[threadID]...)
+			sort.Strings(posts)
+			buffer.WriteString("t:" + threadID + ":" + strings.Join(posts, ",") + "\n")
+		}
+	}
+	return buffer.String()
+}
+
+func sortedKeys(tree consistencyTreeType) (keys []string) {
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// consistencyWriterType keep replacing threads and posts of the dataset,
+// history[i] is the fingerprint of the dataset after i-th commit
+type consistencyWriterType struct {
+	db        *sqlx.DB
+	rand      *rand.Rand
+	tree      consistencyTreeType
+	mutex     sync.Mutex
+	history   []string
+	committed int64
+	writes    int64
+	errors    int64
+}
+
+// newConsistencyWriter insert the dataset, db must not contain any forum
+func newConsistencyWriter(ctx context.Context, db *sqlx.DB) (writer *consistencyWriterType, err error) {
+	forumCount := 0
+	if err = db.GetContext(ctx, &forumCount, `SELECT COUNT(forumID) FROM forums;`); err != nil {
+		return
+	}
+	if forumCount > 0 {
+		return nil, fmt.Errorf("consistency check needs an empty database, found %d forums", forumCount)
+	}
+
+	tree := consistencyTreeType{}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	for fc := 0; fc < consistencyForumCount; fc++ {
+		forumID := uuid.New().String()
+		if err = insertForum(ctx, tx, forumID, "consistency", "consistency"); err != nil {
+			tx.Rollback()
+			return
+		}
+		tree[forumID] = map[string][]string{}
+		for tc := 0; tc < consistencyThreadCount; tc++ {
+			threadID, posts, errInsert := insertConsistencyThread(ctx, tx, forumID)
+			if errInsert != nil {
+				tx.Rollback()
+				return nil, errInsert
+			}
+			tree[forumID][threadID] = posts
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	return &consistencyWriterType{
+		db:      db,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		tree:    tree,
+		history: []string{tree.fingerprint()},
+	}, nil
+}
+
+func insertConsistencyThread(ctx context.Context, tx *sqlx.Tx, forumID string) (threadID string, posts []string, err error) {
+	threadID = uuid.New().String()
+	if err = insertThread(ctx, tx, forumID, threadID, "consistency", "consistency"); err != nil {
+		return
+	}
+	for pc := 0; pc < consistencyPostCount; pc++ {
+		postID := uuid.New().String()
+		if err = insertPost(ctx, tx, threadID, postID, "consistency", "consistency"); err != nil {
+			return
+		}
+		posts = append(posts, postID)
+	}
+	return
+}
+
+// generations return fingerprints of all generations since the one committed at start,
+// including the one being committed now
+func (t *consistencyWriterType) generations(start int64) []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string{}, t.history[start:]...)
+}
+
+func (t *consistencyWriterType) committedGeneration() int64 {
+	return atomic.LoadInt64(&t.committed)
+}
+
+// run write until ctx done, failed writes are counted and skipped
+func (t *consistencyWriterType) run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		if err := t.write(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			atomic.AddInt64(&t.errors, 1)
+		}
+	}
+}
+
+// write replace one thread with all its posts or one post of a thread in a transaction
+func (t *consistencyWriterType) write(ctx context.Context) (err error) {
+	next := t.tree.clone()
+	forumIDs := sortedKeys(next)
+	forumID := forumIDs[t.rand.Intn(len(forumIDs))]
+	threadIDs := make([]string, 0, len(next[forumID]))
+	for threadID := range next[forumID] {
+		threadIDs = append(threadIDs, threadID)
+	}
+	sort.Strings(threadIDs)
+	threadID := threadIDs[t.rand.Intn(len(threadIDs))]
+
+	tx, err := t.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if t.rand.Intn(2) == 0 {
+		if err = deleteThread(ctx, tx, forumID, threadID); err != nil {
+			return
+		}
+		delete(next[forumID], threadID)
+		newThreadID, posts, errInsert := insertConsistencyThread(ctx, tx, forumID)
+		if errInsert != nil {
+			return errInsert
+		}
+		next[forumID][newThreadID] = posts
+	} else {
+		posts := next[forumID][threadID]
+		pi := t.rand.Intn(len(posts))
+		if err = deletePost(ctx, tx, threadID, posts[pi]); err != nil {
+			return
+		}
+		postID := uuid.New().String()
+		if err = insertPost(ctx, tx, threadID, postID, "consistency", "consistency"); err != nil {
+			return
+		}
+		posts[pi] = postID
+	}
+
+	// publish before commit, readers may see the new generation as soon as commit reaches the server
+	t.mutex.Lock()
+	t.history = append(t.history, next.fingerprint())
+	t.mutex.Unlock()
+
+	if err = tx.Commit(); err != nil {
+		t.mutex.Lock()
+		t.history = t.history[:len(t.history)-1]
+		t.mutex.Unlock()
+		return
+	}
+	t.tree = next
+	atomic.AddInt64(&t.committed, 1)
+	atomic.AddInt64(&t.writes, 1)
+	return
+}
+
+// clean delete the dataset
+func (t *consistencyWriterType) clean(ctx context.Context) (err error) {
+	for _, forumID := range sortedKeys(t.tree) {
+		if _, err = t.db.ExecContext(ctx, t.db.Rebind(`DELETE FROM forums WHERE forumID = ?;`), forumID); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type consistencyReportType struct {
+	Target      string
+	Strategy    string
+	TxOption    txOptionType
+	Reads       int
+	Anomalies   int
+	ReadErrors  int
+	Writes      int64
+	WriteErrors int64
+}
+
+func (t consistencyReportType) String() string {
+	return fmt.Sprintf("%s %s %s reads: %d, anomalies: %d, read errors: %d, writes: %d, write errors: %d",
+		t.Target, t.Strategy, t.TxOption, t.Reads, t.Anomalies, t.ReadErrors, t.Writes, t.WriteErrors)
+}
+
+// checkConsistency read with strategy for duration while a writer modify the dataset,
+// a read is an anomaly when the tree doesn't match any generation committed during the read
+func checkConsistency(
+	ctx context.Context,
+	target *targetType,
+	strategy forumdb.Strategy,
+	txOpt txOptionType,
+	duration time.Duration,
+) (report consistencyReportType, err error) {
+	report = consistencyReportType{
+		Target:   target.Label(),
+		Strategy: strategy.Name,
+		TxOption: txOpt,
+	}
+
+	writer, err := newConsistencyWriter(ctx, target.DB)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if errClean := writer.clean(ctx); errClean != nil && err == nil {
+			err = errClean
+		}
+	}()
+
+	writeCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	eg, egCtx := errgroup.WithContext(writeCtx)
+	eg.Go(func() error {
+		return writer.run(egCtx)
+	})
+
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		start := writer.committedGeneration()
+		data, errRead := readConsistency(ctx, target, strategy, txOpt)
+		if errRead != nil {
+			report.ReadErrors++
+			continue
+		}
+		report.Reads++
+
+		fingerprint := newConsistencyTree(data).fingerprint()
+		matched := false
+		for _, generation := range writer.generations(start) {
+			if generation == fingerprint {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report.Anomalies++
+		}
+	}
+
+	stop()
+	if err = eg.Wait(); err != nil {
+		return
+	}
+	report.Writes = atomic.LoadInt64(&writer.writes)
+	report.WriteErrors = atomic.LoadInt64(&writer.errors)
+	return
+}
+
+func readConsistency(ctx context.Context, target *targetType, strategy forumdb.Strategy, txOpt txOptionType) (data []forumdb.Forum, err error) {
+	tx, err := target.beginTx(ctx, strategy, txOpt)
+	if err != nil {
+		return
+	}
+	if data, err = strategy.Select(ctx, tx, forumdb.Request{Limit: forumdb.DefaultLimit}); err != nil {
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	return
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_consistency(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ctx := context.Background()
+
+	targets := append(
+		loadEnvTargets(forumdb.MySQL, "CONSISTENCY_MYSQL_URL"),
+		loadEnvTargets(forumdb.PGSQL, "CONSISTENCY_PGSQL_URL")...,
+	)
+	if len(targets) < 1 {
+		t.Skip("no consistency target defined, set CONSISTENCY_MYSQL_URL or CONSISTENCY_PGSQL_URL to an empty database")
+	}
+
+	isolations := os.Getenv("TX_ISOLATION")
+	if isolations == "" {
+		isolations = "read-committed,repeatable-read,serializable"
+	}
+	txOpts, err := parseTxOptions(isolations, os.Getenv("TX_READ_ONLY"))
+	require.NoError(err)
+
+	duration := 5 * time.Second
+	if env := os.Getenv("CONSISTENCY_DURATION"); env != "" {
+		duration, err = time.ParseDuration(env)
+		require.NoError(err)
+	}
+
+	for _, target := range targets {
+		require.NoError(target.Open(ctx))
+		defer func(target *targetType) {
+			require.NoError(target.Close())
+		}(target)
+
+		for _, strategy := range forumdb.DialectStrategies(target.Dialect) {
+			for _, txOpt := range txOpts {
+				report, err := checkConsistency(ctx, target, strategy, txOpt, duration)
+				require.NoError(err)
+				t.Log(report)
+				assert.NotZero(report.Reads, report.String())
+
+				switch txOpt.Isolation {
+				case "repeatable-read", "serializable":
+					// whole transaction reads one snapshot
+					assert.Zero(report.Anomalies, report.String())
+				}
+			}
+		}
+	}
+}
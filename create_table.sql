@@ -2,7 +2,8 @@ CREATE TABLE forums (
 	forumID VARCHAR(36) NOT NULL PRIMARY KEY,
 	name TEXT,
 	lorem TEXT,
-	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	threadCount INT NOT NULL DEFAULT 0
 );
 
 CREATE TABLE threads (
@@ -11,6 +12,7 @@ CREATE TABLE threads (
 	name TEXT,
 	lorem TEXT,
 	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	postCount INT NOT NULL DEFAULT 0,
 	FOREIGN KEY(forumID) REFERENCES forums(forumID) ON DELETE CASCADE ON UPDATE CASCADE
 );
 
@@ -25,3 +27,10 @@ CREATE TABLE posts (
 
 CREATE INDEX threads_forumID_idx ON threads (forumID);
 CREATE INDEX posts_threadID_idx ON posts (threadID);
+
+-- latest activity workload, newest threads of a forum and newest posts of a thread
+CREATE INDEX threads_forumID_created_idx ON threads (forumID, created);
+CREATE INDEX posts_threadID_created_idx ON posts (threadID, created);
+
+-- search workload needs full-text indexes of threads and posts name and lorem,
+-- MySQL FULLTEXT or PostgreSQL tsvector GIN, created by the index command
@@ -0,0 +1,16 @@
+CREATE TYPE post_type AS (
+	threadID VARCHAR(36),
+	postID VARCHAR(36),
+	name TEXT,
+	lorem TEXT,
+	created TIMESTAMP
+);
+
+CREATE TYPE thread_type AS (
+	forumID VARCHAR(36),
+	threadID VARCHAR(36),
+	name TEXT,
+	lorem TEXT,
+	created TIMESTAMP,
+	posts post_type[]
+);
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
 )
 
 func Test_data(t *testing.T) {
@@ -16,208 +22,290 @@ func Test_data(t *testing.T) {
 
 	ctx := context.Background()
 
-	dbMySQL, err := newMySQLConnection(ctx)
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbMySQL.Close())
-	}()
-
-	txMySQL, err := dbMySQL.BeginTxx(ctx, nil)
-	require.NoError(err)
-	defer func() {
-		if t.Failed() {
-			require.NoError(txMySQL.Rollback())
-		} else {
-			require.NoError(txMySQL.Commit())
-		}
-	}()
-
-	dbPGSQL, err := newPGSQLConnection()
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbPGSQL.Close())
-	}()
-
-	txPGSQL, err := dbPGSQL.BeginTxx(ctx, nil)
-	require.NoError(err)
-	defer func() {
-		if t.Failed() {
-			require.NoError(txPGSQL.Rollback())
-		} else {
-			require.NoError(txPGSQL.Commit())
-		}
-	}()
-
-	// err = insertData(ctx, dbMySQL, dbPGSQL, 5, 5, 5)
-	// require.NoError(err)
-
-	// showMySQLDataCount(ctx, txMySQL, t)
-	// showPGSQLDataCount(ctx, txPGSQL, t)
-
-	// selectData(ctx, txMySQL, t, selectMySQLDataSubQuery)
-	// selectDataMyAppQuery(ctx, txMySQL, t)
-
-	// selectData(ctx, txPGSQL, t, selectPGSQLDataSubQuery)
-	// selectData(ctx, txPGSQL, t, selectPGSQLDataLateralQuery)
-	// selectDataPGAppQuery(ctx, txPGSQL, t)
+	targets := loadAllTargets()
+	require.NotEmpty(targets, "no target defined, set MYSQL_URL or PGSQL_URL")
+
+	for _, target := range targets {
+		require.NoError(target.Open(ctx))
+		defer func(target *targetType) {
+			require.NoError(target.Close())
+		}(target)
+		t.Logf("target %s\n", target.Label())
+
+		tx, err := target.DB.BeginTxx(ctx, nil)
+		require.NoError(err)
+		defer func(tx *sqlx.Tx) {
+			if t.Failed() {
+				require.NoError(tx.Rollback())
+			} else {
+				require.NoError(tx.Commit())
+			}
+		}(tx)
+
+		shape, err := selectDataShape(ctx, tx)
+		require.NoError(err)
+		t.Logf("Total %+v\n", shape)
+	}
 }
 
-func BenchmarkMySQLSelectAppQuery(b *testing.B) {
-	assert := assert.New(b)
-	assert.NotNil(assert)
+// benchmarkTargets run fn as sub benchmark against every target of dialect
+// and every transaction option, sub benchmarks are labelled with
+// target name, server version, isolation level and read-only flag,
+// targets are opened with an instrumented driver counting into stats
+func benchmarkTargets(b *testing.B, dialect forumdb.Dialect, fn func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType)) {
 	require := require.New(b)
 	require.NotNil(require)
 
 	ctx := context.Background()
 
-	dbMySQL, err := newMySQLConnection(ctx)
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbMySQL.Close())
-	}()
+	targets := loadTargets(dialect)
+	require.NotEmpty(targets, "no %s target defined", dialect)
 
-	txMySQL, err := dbMySQL.BeginTxx(ctx, nil)
+	txOpts, err := parseTxOptions(os.Getenv("TX_ISOLATION"), os.Getenv("TX_READ_ONLY"))
 	require.NoError(err)
-	defer func() {
-		if b.Failed() {
-			require.NoError(txMySQL.Rollback())
-		} else {
-			require.NoError(txMySQL.Commit())
-		}
-	}()
 
-	showMySQLDataCount(ctx, txMySQL, b)
-
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		selectDataMyAppQuery(ctx, txMySQL, b)
+	for _, target := range targets {
+		target.DriverStats = &driverStatsType{}
+		require.NoError(target.Open(ctx))
+		for _, txOpt := range txOpts {
+			b.Run(target.Label()+"/"+txOpt.String(), func(b *testing.B) {
+				benchmarkTarget(b, ctx, target, txOpt, fn)
+			})
+		}
+		require.NoError(target.Close())
 	}
 }
 
-func BenchmarkMySQLSelectSubQuery(b *testing.B) {
-	assert := assert.New(b)
-	assert.NotNil(assert)
+func benchmarkTarget(b *testing.B, ctx context.Context, target *targetType, txOpt txOptionType, fn func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType)) {
 	require := require.New(b)
 	require.NotNil(require)
 
-	ctx := context.Background()
-
-	dbMySQL, err := newMySQLConnection(ctx)
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbMySQL.Close())
-	}()
-
-	txMySQL, err := dbMySQL.BeginTxx(ctx, nil)
+	tx, err := beginTx(ctx, target.DB, txOpt)
 	require.NoError(err)
 	defer func() {
 		if b.Failed() {
-			require.NoError(txMySQL.Rollback())
+			require.NoError(tx.Rollback())
 		} else {
-			require.NoError(txMySQL.Commit())
+			require.NoError(tx.Commit())
 		}
 	}()
 
-	showMySQLDataCount(ctx, txMySQL, b)
+	fn(b, ctx, tx, target.DriverStats)
+}
 
+// benchmarkSelect run selectFn b.N times and report driver stats per op,
+// scan-ns/op is the time outside driver calls, spent in sqlx scanning and JSON decoding
+func benchmarkSelect(b *testing.B, stats *driverStatsType, selectFn func() ([]forumdb.Forum, error)) {
+	stats.Reset()
 	b.ResetTimer()
+	start := time.Now()
 	for n := 0; n < b.N; n++ {
-		selectData(ctx, txMySQL, b, selectMySQLDataSubQuery)
+		result, err := selectFn()
+		require.NoError(b, err)
+		showDataCounts(b, result)
 	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	total := stats.Load()
+	ops := float64(b.N)
+	b.ReportMetric(float64(total.Queries)/ops, "queries/op")
+	b.ReportMetric(float64(total.Rows)/ops, "rows/op")
+	b.ReportMetric(float64(total.Bytes)/ops, "bytes/op")
+	b.ReportMetric(float64(total.DriverTime)/ops, "driver-ns/op")
+	b.ReportMetric(float64(elapsed-total.DriverTime)/ops, "scan-ns/op")
+}
+
+// benchmarkLoader benchmark strategy name of dialect with default limit
+func benchmarkLoader(b *testing.B, dialect forumdb.Dialect, name string) {
+	loader, err := forumdb.NewLoader(dialect, name)
+	require.NoError(b, err)
+
+	benchmarkTargets(b, dialect, func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType) {
+		shape, err := selectDataShape(ctx, tx)
+		require.NoError(b, err)
+		b.Logf("Total %+v\n", shape)
+
+		benchmarkSelect(b, stats, func() ([]forumdb.Forum, error) {
+			return loader.Load(ctx, tx, forumdb.DefaultLimit)
+		})
+	})
+}
+
+func BenchmarkMySQLSelectAppQuery(b *testing.B) {
+	benchmarkLoader(b, forumdb.MySQL, "app-query")
+}
+
+func BenchmarkMySQLSelectSubQuery(b *testing.B) {
+	benchmarkLoader(b, forumdb.MySQL, "sub-query")
 }
 
 func BenchmarkPGSQLSelectAppQuery(b *testing.B) {
-	assert := assert.New(b)
-	assert.NotNil(assert)
-	require := require.New(b)
-	require.NotNil(require)
+	benchmarkLoader(b, forumdb.PGSQL, "app-query")
+}
 
-	ctx := context.Background()
+func BenchmarkPGSQLSelectSubQuery(b *testing.B) {
+	benchmarkLoader(b, forumdb.PGSQL, "sub-query")
+}
 
-	dbPGSQL, err := newPGSQLConnection()
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbPGSQL.Close())
-	}()
+func BenchmarkPGSQLSelectLateralQuery(b *testing.B) {
+	benchmarkLoader(b, forumdb.PGSQL, "lateral")
+}
 
-	txPGSQL, err := dbPGSQL.BeginTxx(ctx, nil)
-	require.NoError(err)
-	defer func() {
-		if b.Failed() {
-			require.NoError(txPGSQL.Rollback())
-		} else {
-			require.NoError(txPGSQL.Commit())
+// benchmarkPageDepths are page depths of page benchmarks, counted from 0
+var benchmarkPageDepths = []int{0, 10, 100, 1000}
+
+// benchmarkPages benchmark strategy name of dialect loading keyset and offset pages of default limit at every depth,
+// depths beyond the data are skipped
+func benchmarkPages(b *testing.B, dialect forumdb.Dialect, name string) {
+	loader, err := forumdb.NewLoader(dialect, name)
+	require.NoError(b, err)
+
+	benchmarkTargets(b, dialect, func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType) {
+		for _, mode := range forumdb.PageModes {
+			for _, depth := range benchmarkPageDepths {
+				b.Run(fmt.Sprintf("%s:%d", mode, depth), func(b *testing.B) {
+					page, err := forumdb.NewPage(ctx, tx, dialect, mode, depth, forumdb.DefaultLimit.Forums)
+					if err != nil {
+						b.Skip(err)
+					}
+					benchmarkSelect(b, stats, func() ([]forumdb.Forum, error) {
+						return loader.LoadPage(ctx, tx, forumdb.DefaultLimit, page)
+					})
+				})
+			}
 		}
-	}()
+	})
+}
 
-	showPGSQLDataCount(ctx, txPGSQL, b)
+func BenchmarkMySQLPageAppQuery(b *testing.B) {
+	benchmarkPages(b, forumdb.MySQL, "app-query")
+}
 
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		selectDataPGAppQuery(ctx, txPGSQL, b)
-	}
+func BenchmarkMySQLPageSubQuery(b *testing.B) {
+	benchmarkPages(b, forumdb.MySQL, "sub-query")
 }
 
-func BenchmarkPGSQLSelectSubQuery(b *testing.B) {
-	assert := assert.New(b)
-	assert.NotNil(assert)
-	require := require.New(b)
-	require.NotNil(require)
+func BenchmarkPGSQLPageAppQuery(b *testing.B) {
+	benchmarkPages(b, forumdb.PGSQL, "app-query")
+}
 
-	ctx := context.Background()
+func BenchmarkPGSQLPageSubQuery(b *testing.B) {
+	benchmarkPages(b, forumdb.PGSQL, "sub-query")
+}
 
-	dbPGSQL, err := newPGSQLConnection()
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbPGSQL.Close())
-	}()
+func BenchmarkPGSQLPageLateralQuery(b *testing.B) {
+	benchmarkPages(b, forumdb.PGSQL, "lateral")
+}
 
-	txPGSQL, err := dbPGSQL.BeginTxx(ctx, nil)
-	require.NoError(err)
-	defer func() {
-		if b.Failed() {
-			require.NoError(txPGSQL.Rollback())
-		} else {
-			require.NoError(txPGSQL.Commit())
+// benchmarkLookups benchmark every point lookup strategy of dialect with every forum distribution,
+// each iteration loads one forum with default limit
+func benchmarkLookups(b *testing.B, dialect forumdb.Dialect) {
+	benchmarkTargets(b, dialect, func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType) {
+		ids := []string{}
+		require.NoError(b, sqlx.SelectContext(ctx, tx, &ids, selectForumIDsQuery))
+		require.NotEmpty(b, ids)
+
+		for _, strategy := range forumdb.DialectLookupStrategies(dialect) {
+			loader := forumdb.Loader{Strategy: strategy, Decoder: forumdb.DefaultDecoder}
+			for _, lookup := range lookupTypes {
+				b.Run(strategy.Name+"/"+string(lookup), func(b *testing.B) {
+					picker := newForumPicker(lookup, ids, 1)
+					benchmarkSelect(b, stats, func() ([]forumdb.Forum, error) {
+						forum, err := loader.Lookup(ctx, tx, picker.Next(), forumdb.DefaultLimit)
+						return []forumdb.Forum{forum}, err
+					})
+				})
+			}
 		}
-	}()
+	})
+}
 
-	showPGSQLDataCount(ctx, txPGSQL, b)
+func BenchmarkMySQLLookup(b *testing.B) {
+	benchmarkLookups(b, forumdb.MySQL)
+}
 
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		selectData(ctx, txPGSQL, b, selectPGSQLDataSubQuery)
-	}
+func BenchmarkPGSQLLookup(b *testing.B) {
+	benchmarkLookups(b, forumdb.PGSQL)
 }
 
-func BenchmarkPGSQLSelectLateralQuery(b *testing.B) {
-	assert := assert.New(b)
-	assert.NotNil(assert)
-	require := require.New(b)
-	require.NotNil(require)
+// benchmarkLatest benchmark every latest activity strategy of dialect with default limit
+func benchmarkLatest(b *testing.B, dialect forumdb.Dialect) {
+	benchmarkTargets(b, dialect, func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType) {
+		for _, strategy := range forumdb.DialectLatestStrategies(dialect) {
+			if strategy.Driver != "" {
+				// benchmark transactions are of the default driver
+				continue
+			}
+			loader := forumdb.Loader{Strategy: strategy, Decoder: forumdb.DefaultDecoder}
+			b.Run(strategy.Name, func(b *testing.B) {
+				benchmarkSelect(b, stats, func() ([]forumdb.Forum, error) {
+					return loader.Load(ctx, tx, forumdb.DefaultLimit)
+				})
+			})
+		}
+	})
+}
 
-	ctx := context.Background()
+func BenchmarkMySQLLatest(b *testing.B) {
+	benchmarkLatest(b, forumdb.MySQL)
+}
 
-	dbPGSQL, err := newPGSQLConnection()
-	require.NoError(err)
-	defer func() {
-		require.NoError(dbPGSQL.Close())
-	}()
+func BenchmarkPGSQLLatest(b *testing.B) {
+	benchmarkLatest(b, forumdb.PGSQL)
+}
 
-	txPGSQL, err := dbPGSQL.BeginTxx(ctx, nil)
-	require.NoError(err)
-	defer func() {
-		if b.Failed() {
-			require.NoError(txPGSQL.Rollback())
-		} else {
-			require.NoError(txPGSQL.Commit())
+// benchmarkAggregate benchmark every strategy of dialect with every aggregate it supports
+func benchmarkAggregate(b *testing.B, dialect forumdb.Dialect) {
+	benchmarkTargets(b, dialect, func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType) {
+		for _, strategy := range forumdb.DialectStrategies(dialect) {
+			if strategy.Driver != "" {
+				// benchmark transactions are of the default driver
+				continue
+			}
+			for _, aggregate := range forumdb.Aggregates {
+				loader := forumdb.Loader{Strategy: forumdb.WithAggregate(strategy, aggregate), Decoder: forumdb.DefaultDecoder}
+				b.Run(strategy.Name+"/"+string(aggregate), func(b *testing.B) {
+					if !aggregate.Supports(strategy) {
+						b.Skipf("strategy %s doesn't support aggregate %s", strategy.Name, aggregate)
+					}
+					benchmarkSelect(b, stats, func() ([]forumdb.Forum, error) {
+						return loader.Load(ctx, tx, forumdb.DefaultLimit)
+					})
+				})
+			}
 		}
-	}()
+	})
+}
 
-	showPGSQLDataCount(ctx, txPGSQL, b)
+func BenchmarkMySQLAggregate(b *testing.B) {
+	benchmarkAggregate(b, forumdb.MySQL)
+}
 
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		selectData(ctx, txPGSQL, b, selectPGSQLDataLateralQuery)
-	}
+func BenchmarkPGSQLAggregate(b *testing.B) {
+	benchmarkAggregate(b, forumdb.PGSQL)
+}
+
+// benchmarkSearch benchmark every search strategy of dialect with uniformly picked terms drawn from the lorem vocabulary
+func benchmarkSearch(b *testing.B, dialect forumdb.Dialect) {
+	terms := drawSearchTerms(searchTermCount)
+	benchmarkTargets(b, dialect, func(b *testing.B, ctx context.Context, tx *sqlx.Tx, stats *driverStatsType) {
+		for _, strategy := range forumdb.DialectSearchStrategies(dialect) {
+			loader := forumdb.Loader{Strategy: strategy, Decoder: forumdb.DefaultDecoder}
+			b.Run(strategy.Name, func(b *testing.B) {
+				picker := newForumPicker(lookupRandom, terms, 1)
+				benchmarkSelect(b, stats, func() ([]forumdb.Forum, error) {
+					return loader.Search(ctx, tx, picker.Next(), forumdb.DefaultLimit)
+				})
+			})
+		}
+	})
+}
+
+func BenchmarkMySQLSearch(b *testing.B) {
+	benchmarkSearch(b, forumdb.MySQL)
+}
+
+func BenchmarkPGSQLSearch(b *testing.B) {
+	benchmarkSearch(b, forumdb.PGSQL)
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// planType is the captured plan of one SQL statement issued by a strategy
+type planType struct {
+	Query string `json:"query"`
+	// Format is the explain variant, e.g. "analyze-json" or "json"
+	Format string `json:"format"`
+	Plan   string `json:"plan"`
+}
+
+// statementType is one SQL statement with its arguments
+type statementType struct {
+	Query string
+	Args  []interface{}
+}
+
+// recordQueryerType pass queries to QueryerContext and record every issued statement
+type recordQueryerType struct {
+	sqlx.QueryerContext
+	Statements []statementType
+}
+
+func (t *recordQueryerType) record(query string, args []interface{}) {
+	t.Statements = append(t.Statements, statementType{Query: query, Args: args})
+}
+
+func (t *recordQueryerType) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	t.record(query, args)
+	return t.QueryerContext.QueryContext(ctx, query, args...)
+}
+
+func (t *recordQueryerType) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	t.record(query, args)
+	return t.QueryerContext.QueryxContext(ctx, query, args...)
+}
+
+func (t *recordQueryerType) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	t.record(query, args)
+	return t.QueryerContext.QueryRowxContext(ctx, query, args...)
+}
+
+// QueryPGX record statement and pass it to QueryerContext, which must implement forumdb.PGXQueryer
+func (t *recordQueryerType) QueryPGX(ctx context.Context, fn func(rows pgx.Rows) error, query string, args ...interface{}) error {
+	queryer, ok := t.QueryerContext.(forumdb.PGXQueryer)
+	if !ok {
+		return fmt.Errorf("queryer %T is not pgx", t.QueryerContext)
+	}
+	t.record(query, args)
+	return queryer.QueryPGX(ctx, fn, query, args...)
+}
+
+// distinctStatements return the first statement of every query text in issued order
+func (t *recordQueryerType) distinctStatements() (statements []statementType) {
+	seen := map[string]bool{}
+	for _, statement := range t.Statements {
+		if seen[statement.Query] {
+			continue
+		}
+		seen[statement.Query] = true
+		statements = append(statements, statement)
+	}
+	return
+}
+
+// explainFormats map dialect to explain variants, MySQL EXPLAIN ANALYZE needs 8.0.18 or later
+var explainFormats = map[forumdb.Dialect][]struct {
+	Format   string
+	Prefix   string
+	Optional bool
+}{
+	forumdb.MySQL: {
+		{Format: "json", Prefix: "EXPLAIN FORMAT=JSON "},
+		{Format: "analyze", Prefix: "EXPLAIN ANALYZE ", Optional: true},
+	},
+	forumdb.PGSQL: {
+		{Format: "analyze-json", Prefix: "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "},
+	},
+}
+
+// explainCase run the strategy of runCase once, then explain every statement it issued with the same arguments,
+// all in one transaction rolled back at the end
+func explainCase(ctx context.Context, target *targetType, runCase runCaseType) (plans []planType, err error) {
+	tx, err := target.beginTx(ctx, runCase.Strategy, runCase.TxOption)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	recorder := &recordQueryerType{QueryerContext: tx}
+	if _, err = runCase.Strategy.Select(ctx, recorder, runCase.request(nil, runCase.picker(target, 1))); err != nil {
+		return
+	}
+
+	for _, statement := range recorder.distinctStatements() {
+		for _, format := range explainFormats[target.Dialect] {
+			plan, errExplain := explainStatement(ctx, tx, format.Prefix+strings.TrimSpace(statement.Query), statement.Args)
+			if errExplain != nil {
+				if format.Optional {
+					continue
+				}
+				return nil, errExplain
+			}
+			plans = append(plans, planType{
+				Query:  statement.Query,
+				Format: format.Format,
+				Plan:   plan,
+			})
+		}
+	}
+	return
+}
+
+// explainStatement return all lines of the first column of explain output
+func explainStatement(ctx context.Context, tx sqlx.QueryerContext, query string, args []interface{}) (plan string, err error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	lines := []string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+		lines = append(lines, values[0].String)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return strings.Join(lines, "\n"), nil
+}
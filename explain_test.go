@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_distinctStatements(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	loader, err := forumdb.NewLoader(forumdb.PGSQL, "app-query")
+	require.NoError(err)
+	require.Len(loader.Strategy.Queries, 3)
+	selectPGSQLForumsQuery := loader.Strategy.Queries[0]
+	selectPGSQLThreadsQuery := loader.Strategy.Queries[1]
+	selectPGSQLPostsQuery := loader.Strategy.Queries[2]
+
+	recorder := &recordQueryerType{}
+	recorder.record(selectPGSQLForumsQuery, []interface{}{10})
+	recorder.record(selectPGSQLThreadsQuery, []interface{}{"f1", 10})
+	recorder.record(selectPGSQLPostsQuery, []interface{}{"t1", 10})
+	recorder.record(selectPGSQLThreadsQuery, []interface{}{"f2", 10})
+	recorder.record(selectPGSQLPostsQuery, []interface{}{"t2", 10})
+
+	statements := recorder.distinctStatements()
+	require.Len(statements, 3)
+	assert.Equal(selectPGSQLForumsQuery, statements[0].Query)
+	assert.Equal(selectPGSQLThreadsQuery, statements[1].Query)
+	assert.Equal([]interface{}{"f1", 10}, statements[1].Args)
+	assert.Equal(selectPGSQLPostsQuery, statements[2].Query)
+}
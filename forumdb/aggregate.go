@@ -0,0 +1,257 @@
+package forumdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jmoiron/sqlx"
+)
+
+// Aggregate is how a strategy load total thread count of every forum and total post count of every thread
+type Aggregate string
+
+// supported aggregates
+const (
+	// AggregateNone load no count
+	AggregateNone Aggregate = ""
+	// AggregateSubQuery count children by correlated sub-queries inside the queries of the strategy
+	AggregateSubQuery Aggregate = "sub-query"
+	// AggregateAppQuery count children by one extra statement per loaded forum and per loaded thread after the tree
+	AggregateAppQuery Aggregate = "app-query"
+	// AggregateCounter read denormalised counter columns by primary key inside the queries of the strategy
+	AggregateCounter Aggregate = "counter"
+)
+
+// Aggregates are all aggregates except AggregateNone
+var Aggregates = []Aggregate{AggregateSubQuery, AggregateAppQuery, AggregateCounter}
+
+// AggregateByName return aggregate of name
+func AggregateByName(name string) (Aggregate, error) {
+	for _, aggregate := range Aggregates {
+		if string(aggregate) == name {
+			return aggregate, nil
+		}
+	}
+	return AggregateNone, fmt.Errorf("unknown aggregate %q", name)
+}
+
+// aggregateCountsType are count expressions inserted into strategy queries,
+// f is the alias of forums and t the alias of threads in every strategy query
+type aggregateCountsType struct {
+	ThreadCount string
+	PostCount   string
+}
+
+// aggregateCounts are count expressions of aggregates counting inside the strategy queries
+var aggregateCounts = map[Aggregate]aggregateCountsType{
+	AggregateSubQuery: {
+		ThreadCount: "(SELECT COUNT(*) FROM threads ac WHERE ac.forumID = f.forumID)",
+		PostCount:   "(SELECT COUNT(*) FROM posts ac WHERE ac.threadID = t.threadID)",
+	},
+	AggregateCounter: {
+		ThreadCount: "(SELECT ac.threadCount FROM forums ac WHERE ac.forumID = f.forumID)",
+		PostCount:   "(SELECT ac.postCount FROM threads ac WHERE ac.threadID = t.threadID)",
+	},
+}
+
+// aggregateAnchorType is a text of strategy queries where counts are inserted,
+// {threadCount} and {postCount} of Replace are the count expressions
+type aggregateAnchorType struct {
+	Anchor  string
+	Replace string
+	// Forum and Thread are true when Replace add the thread count of forums and the post count of threads
+	Forum  bool
+	Thread bool
+}
+
+// aggregateAnchors are texts of strategy queries building forums and threads, like forumsSource of pages
+var aggregateAnchors = []aggregateAnchorType{
+	// forum JSON built by the database
+	{Anchor: "'created', f.created", Replace: "'threadCount', {threadCount}, 'created', f.created", Forum: true},
+	// thread JSON built by the database
+	{Anchor: "'created', t.created", Replace: "'postCount', {postCount}, 'created', t.created", Thread: true},
+	// thread rows of app-query strategies
+	{Anchor: "t.created\nFROM threads t", Replace: "t.created, {postCount} AS \"postCount\"\nFROM threads t", Thread: true},
+	// forum and thread columns of flat-join strategies, scanned by flatRowType
+	{
+		Anchor:  "f.created,\n\tt.threadID, t.name, t.lorem, t.created,",
+		Replace: "f.created, {threadCount} AS threadCount,\n\tt.threadID, t.name, t.lorem, t.created, {postCount} AS postCount,",
+		Forum:   true,
+		Thread:  true,
+	},
+}
+
+// rewrite return query with counts inserted at every anchor, and whether forum and thread counts were inserted
+func (t aggregateCountsType) rewrite(query string) (result string, forum bool, thread bool) {
+	replacer := strings.NewReplacer("{threadCount}", t.ThreadCount, "{postCount}", t.PostCount)
+	result = query
+	for _, anchor := range aggregateAnchors {
+		if !strings.Contains(result, anchor.Anchor) {
+			continue
+		}
+		result = strings.Replace(result, anchor.Anchor, replacer.Replace(anchor.Replace), -1)
+		forum = forum || anchor.Forum
+		thread = thread || anchor.Thread
+	}
+	return
+}
+
+// Supports return true when aggregate can load counts of strategy,
+// native pgx strategies decode fixed composite types without room for counts
+func (t Aggregate) Supports(strategy Strategy) bool {
+	counts, ok := aggregateCounts[t]
+	if !ok {
+		return true
+	}
+	forums, threads := false, false
+	for _, query := range strategy.Queries {
+		_, forum, thread := counts.rewrite(query)
+		forums = forums || forum
+		threads = threads || thread
+	}
+	return forums && threads
+}
+
+// WithAggregate return strategy also loading ThreadCount of forums and PostCount of threads by aggregate,
+// AggregateAppQuery load counts after the tree in the same tx and doesn't support streaming mode,
+// other aggregates count inside the queries of strategy when Supports
+func WithAggregate(strategy Strategy, aggregate Aggregate) Strategy {
+	if aggregate == AggregateAppQuery {
+		return withAggregateAppQuery(strategy)
+	}
+	counts, ok := aggregateCounts[aggregate]
+	if !ok {
+		return strategy
+	}
+
+	result := strategy
+	result.Queries = []string{}
+	for _, query := range strategy.Queries {
+		query, _, _ = counts.rewrite(query)
+		result.Queries = append(result.Queries, query)
+	}
+	supported := aggregate.Supports(strategy)
+	result.Select = func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+		if !supported {
+			return nil, fmt.Errorf("aggregate %s is not supported by strategy %s", aggregate, strategy.Name)
+		}
+		return strategy.Select(ctx, &aggregateQueryerType{QueryerContext: tx, aggregate: aggregate, counts: counts}, request)
+	}
+	return result
+}
+
+type aggregateQueryKeyType struct {
+	Aggregate Aggregate
+	Query     string
+}
+
+// aggregateQueries cache rewritten text of every query, so iterations don't rebuild it
+var aggregateQueries = sync.Map{}
+
+// aggregateQueryerType pass queries to QueryerContext with counts of aggregate inserted
+type aggregateQueryerType struct {
+	sqlx.QueryerContext
+	aggregate Aggregate
+	counts    aggregateCountsType
+}
+
+func (t *aggregateQueryerType) rewrite(query string) string {
+	key := aggregateQueryKeyType{Aggregate: t.aggregate, Query: query}
+	rewritten, ok := aggregateQueries.Load(key)
+	if !ok {
+		rewritten, _, _ = t.counts.rewrite(query)
+		aggregateQueries.Store(key, rewritten)
+	}
+	return rewritten.(string)
+}
+
+func (t *aggregateQueryerType) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.QueryerContext.QueryContext(ctx, t.rewrite(query), args...)
+}
+
+func (t *aggregateQueryerType) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return t.QueryerContext.QueryxContext(ctx, t.rewrite(query), args...)
+}
+
+func (t *aggregateQueryerType) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return t.QueryerContext.QueryRowxContext(ctx, t.rewrite(query), args...)
+}
+
+// QueryPGX implement PGXQueryer, composite types of native pgx queries have no count field
+func (t *aggregateQueryerType) QueryPGX(ctx context.Context, fn func(rows pgx.Rows) error, query string, args ...interface{}) error {
+	return fmt.Errorf("aggregate %s is not supported by native pgx queries", t.aggregate)
+}
+
+// withAggregateAppQuery return strategy loading counts by one statement per forum and per thread after the tree
+func withAggregateAppQuery(strategy Strategy) Strategy {
+	threadCountQuery := rebind(strategy.Dialect, selectThreadCountAppQuery)
+	postCountQuery := rebind(strategy.Dialect, selectPostCountAppQuery)
+
+	result := strategy
+	result.Queries = append(append([]string{}, strategy.Queries...), threadCountQuery, postCountQuery)
+	result.Select = func(ctx context.Context, tx sqlx.QueryerContext, request Request) (forums []Forum, err error) {
+		if request.OnForum != nil {
+			return nil, errors.New("app-query aggregate is not supported in streaming mode")
+		}
+		if forums, err = strategy.Select(ctx, tx, request); err != nil {
+			return
+		}
+		err = selectAggregatesAppQuery(ctx, tx, request, forums, threadCountQuery, postCountQuery)
+		return
+	}
+	return result
+}
+
+// selectAggregatesAppQuery set counts by one statement per forum and per thread
+func selectAggregatesAppQuery(
+	ctx context.Context,
+	tx sqlx.QueryerContext,
+	request Request,
+	forums []Forum,
+	threadCountQuery string,
+	postCountQuery string,
+) (err error) {
+	selectCount := func(query string, id string, count *int64) error {
+		return queryRows(ctx, tx, request.Phases, func(rows *sqlx.Rows) error {
+			return rows.Scan(count)
+		}, query, id)
+	}
+	for fi := range forums {
+		forum := &forums[fi]
+		if err = selectCount(threadCountQuery, forum.ForumID, &forum.ThreadCount); err != nil {
+			return
+		}
+		for ti := range forum.Threads {
+			thread := &forum.Threads[ti]
+			if err = selectCount(postCountQuery, thread.ThreadID, &thread.PostCount); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// rebind return query with ? placeholders in the form of dialect
+func rebind(dialect Dialect, query string) string {
+	if dialect == PGSQL {
+		return sqlx.Rebind(sqlx.DOLLAR, query)
+	}
+	return query
+}
+
+const selectThreadCountAppQuery = `
+SELECT COUNT(*) AS threadCount
+FROM threads
+WHERE forumID = ?
+;`
+
+const selectPostCountAppQuery = `
+SELECT COUNT(*) AS postCount
+FROM posts
+WHERE threadID = ?
+;`
@@ -0,0 +1,108 @@
+package forumdb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithAggregate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	aggregate, err := AggregateByName("counter")
+	require.NoError(err)
+	assert.Equal(AggregateCounter, aggregate)
+	_, err = AggregateByName("trigger")
+	assert.Error(err)
+
+	for _, strategies := range [][]Strategy{Strategies, LatestStrategies, LookupStrategies, SearchStrategies} {
+		for _, strategy := range strategies {
+			assert.Equal(strategy.Queries, WithAggregate(strategy, AggregateNone).Queries, strategy.Name)
+
+			wrapped := WithAggregate(strategy, AggregateAppQuery)
+			require.Len(wrapped.Queries, len(strategy.Queries)+2, strategy.Name)
+			for _, query := range wrapped.Queries[len(strategy.Queries):] {
+				assert.Equal(strategy.Dialect == PGSQL, strings.Contains(query, "$1"), query)
+			}
+
+			for _, aggregate := range []Aggregate{AggregateSubQuery, AggregateCounter} {
+				wrapped := WithAggregate(strategy, aggregate)
+				assert.Equal(strategy.Name, wrapped.Name)
+				assert.Equal(strategy.Driver, wrapped.Driver)
+				require.Len(wrapped.Queries, len(strategy.Queries), strategy.Name)
+				if strategy.Name == "composite-array" {
+					assert.False(aggregate.Supports(strategy), strategy.Name)
+					assert.Equal(strategy.Queries, wrapped.Queries, strategy.Name)
+					continue
+				}
+				assert.True(aggregate.Supports(strategy), "%s %s %s", strategy.Dialect, strategy.Name, aggregate)
+				counts := aggregateCounts[aggregate]
+				queries := strings.Join(wrapped.Queries, "")
+				assert.Contains(queries, counts.ThreadCount, strategy.Name)
+				assert.Contains(queries, counts.PostCount, strategy.Name)
+			}
+		}
+	}
+
+	counts, forum, thread := aggregateCounts[AggregateSubQuery].rewrite(selectPGSQLDataFlatJoinQuery)
+	assert.True(forum)
+	assert.True(thread)
+	assert.Contains(counts, "f.created, (SELECT COUNT(*) FROM threads ac WHERE ac.forumID = f.forumID) AS threadCount,\n")
+	assert.Contains(counts, "t.created, (SELECT COUNT(*) FROM posts ac WHERE ac.threadID = t.threadID) AS postCount,\n")
+
+	streaming := Request{Limit: DefaultLimit, OnForum: func(forum Forum) error { return nil }}
+	_, err = WithAggregate(Strategies[0], AggregateAppQuery).Select(context.Background(), nil, streaming)
+	assert.Error(err)
+
+	unsupported := Strategies[len(Strategies)-1]
+	for _, strategy := range Strategies {
+		if strategy.Name == "composite-array" {
+			unsupported = strategy
+		}
+	}
+	_, err = WithAggregate(unsupported, AggregateCounter).Select(context.Background(), nil, Request{Limit: DefaultLimit})
+	assert.EqualError(err, "aggregate counter is not supported by strategy composite-array")
+
+	assert.Equal("forums.threadCount", Counters[0].String())
+}
+
+// execRecorderType record statements of ExecContext, other methods are not implemented
+type execRecorderType struct {
+	sqlx.ExtContext
+	queries []string
+	args    [][]interface{}
+}
+
+func (t *execRecorderType) Rebind(query string) string {
+	return sqlx.Rebind(sqlx.DOLLAR, query)
+}
+
+func (t *execRecorderType) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	t.queries = append(t.queries, query)
+	t.args = append(t.args, args)
+	return nil, nil
+}
+
+func Test_CounterAdd(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	tx := &execRecorderType{}
+	require.NoError(ThreadCounter.Add(context.Background(), tx, "forum-1", 1))
+	require.NoError(PostCounter.Add(context.Background(), tx, "thread-1", -1))
+	assert.Equal([]string{
+		"UPDATE forums SET threadCount = threadCount + $1 WHERE forumID = $2",
+		"UPDATE threads SET postCount = postCount + $1 WHERE threadID = $2",
+	}, tx.queries)
+	assert.Equal([][]interface{}{{1, "forum-1"}, {-1, "thread-1"}}, tx.args)
+}
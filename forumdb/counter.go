@@ -0,0 +1,99 @@
+package forumdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Counter is a denormalised count column of child rows, read by AggregateCounter
+type Counter struct {
+	Table  string
+	Key    string
+	Column string
+	// Child is the counted table referencing Key of Table
+	Child string
+}
+
+func (t Counter) String() string {
+	return t.Table + "." + t.Column
+}
+
+// counters of AggregateCounter
+var (
+	// ThreadCounter is the thread count of forums
+	ThreadCounter = Counter{Table: "forums", Key: "forumID", Column: "threadCount", Child: "threads"}
+	// PostCounter is the post count of threads
+	PostCounter = Counter{Table: "threads", Key: "threadID", Column: "postCount", Child: "posts"}
+)
+
+// Counters are thread count of forums and post count of threads
+var Counters = []Counter{ThreadCounter, PostCounter}
+
+// Exists return true when counter column exists on table
+func (t Counter) Exists(ctx context.Context, queryer sqlx.QueryerContext, dialect Dialect) (exists bool, err error) {
+	count := 0
+	switch dialect {
+	case MySQL:
+		err = sqlx.GetContext(ctx, queryer, &count, selectMySQLColumnCountQuery, t.Table, t.Column)
+	case PGSQL:
+		// unquoted identifiers are folded to lower case
+		err = sqlx.GetContext(ctx, queryer, &count, selectPGSQLColumnCountQuery, strings.ToLower(t.Table), strings.ToLower(t.Column))
+	default:
+		err = fmt.Errorf("unknown dialect %q", dialect)
+	}
+	return count > 0, err
+}
+
+// CreateCounters add every counter column not existing yet, return created counters,
+// created counters are zero until RefreshCounters
+func CreateCounters(ctx context.Context, db sqlx.ExtContext, dialect Dialect, counters []Counter) (created []Counter, err error) {
+	for _, counter := range counters {
+		exists, err := counter.Exists(ctx, db, dialect)
+		if err != nil {
+			return created, err
+		}
+		if exists {
+			continue
+		}
+		if _, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s INT NOT NULL DEFAULT 0", counter.Table, counter.Column)); err != nil {
+			return created, err
+		}
+		created = append(created, counter)
+	}
+	return
+}
+
+// Add add delta to counter of row key, call it in the transaction inserting or deleting the child row
+// so readers never see the counter and the rows apart
+func (t Counter) Add(ctx context.Context, tx sqlx.ExtContext, key string, delta int) (err error) {
+	query := fmt.Sprintf("UPDATE %[1]s SET %[2]s = %[2]s + ? WHERE %[3]s = ?", t.Table, t.Column, t.Key)
+	_, err = tx.ExecContext(ctx, tx.Rebind(query), delta, key)
+	return
+}
+
+// RefreshCounters set every counter column to the current child row count
+func RefreshCounters(ctx context.Context, db sqlx.ExecerContext, counters []Counter) (err error) {
+	for _, counter := range counters {
+		query := fmt.Sprintf("UPDATE %[1]s SET %[2]s = (SELECT COUNT(*) FROM %[3]s c WHERE c.%[4]s = %[1]s.%[4]s)",
+			counter.Table, counter.Column, counter.Child, counter.Key)
+		if _, err = db.ExecContext(ctx, query); err != nil {
+			return
+		}
+	}
+	return
+}
+
+const selectMySQLColumnCountQuery = `
+SELECT COUNT(*)
+FROM information_schema.columns
+WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+;`
+
+const selectPGSQLColumnCountQuery = `
+SELECT COUNT(*)
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+;`
@@ -0,0 +1,395 @@
+package forumdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/tsaikd/KDGoLib/sqlutil"
+)
+
+// Decoder decode forum JSON built by the database
+type Decoder struct {
+	Name   string
+	Decode func(data []byte, forum *Forum) error
+}
+
+// Decoders are all available decoders
+var Decoders = []Decoder{
+	{
+		Name: "strict",
+		Decode: func(data []byte, forum *Forum) error {
+			return sqlutil.SQLScanStrictJSON(forum, data)
+		},
+	},
+	{
+		Name: "std",
+		Decode: func(data []byte, forum *Forum) error {
+			return json.Unmarshal(data, forum)
+		},
+	},
+	{
+		Name:   "fast",
+		Decode: decodeForumFast,
+	},
+}
+
+// DefaultDecoder is the decoder of Forum.Scan
+var DefaultDecoder = Decoders[0]
+
+// DecoderByName return decoder of name
+func DecoderByName(name string) (result Decoder, err error) {
+	for _, decoder := range Decoders {
+		if decoder.Name == name {
+			return decoder, nil
+		}
+	}
+	return result, fmt.Errorf("unknown decoder %q", name)
+}
+
+// decodeForumFast decode forum JSON without reflection, unknown keys are skipped,
+// it is hand written instead of generated to keep the tree free of generator dependencies,
+// Test_decodeForumFastFields fails when a model field is not decoded
+func decodeForumFast(data []byte, forum *Forum) (err error) {
+	decoder := &fastDecoderType{data: data}
+	if err = decoder.forum(forum); err != nil {
+		return
+	}
+	decoder.skipSpace()
+	if decoder.pos < len(decoder.data) {
+		return decoder.errorf("unexpected data after forum")
+	}
+	return
+}
+
+// fastDecoderType is a hand written JSON scanner for the forum tree
+type fastDecoderType struct {
+	data []byte
+	pos  int
+}
+
+func (t *fastDecoderType) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("fast decoder at offset %d: %s", t.pos, fmt.Sprintf(format, args...))
+}
+
+func (t *fastDecoderType) skipSpace() {
+	for t.pos < len(t.data) {
+		switch t.data[t.pos] {
+		case ' ', '\t', '\n', '\r':
+			t.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (t *fastDecoderType) peek() byte {
+	t.skipSpace()
+	if t.pos >= len(t.data) {
+		return 0
+	}
+	return t.data[t.pos]
+}
+
+func (t *fastDecoderType) expect(c byte) error {
+	if t.peek() != c {
+		return t.errorf("expect %q", c)
+	}
+	t.pos++
+	return nil
+}
+
+// null consume null and return true when next value is null
+func (t *fastDecoderType) null() bool {
+	if t.peek() == 'n' && t.pos+4 <= len(t.data) && string(t.data[t.pos:t.pos+4]) == "null" {
+		t.pos += 4
+		return true
+	}
+	return false
+}
+
+// object call field for every key, field must consume the value
+func (t *fastDecoderType) object(field func(key string) error) (err error) {
+	if t.null() {
+		return
+	}
+	if err = t.expect('{'); err != nil {
+		return
+	}
+	if t.peek() == '}' {
+		t.pos++
+		return
+	}
+	for {
+		key, err := t.string()
+		if err != nil {
+			return err
+		}
+		if err = t.expect(':'); err != nil {
+			return err
+		}
+		if err = field(key); err != nil {
+			return err
+		}
+		switch t.peek() {
+		case ',':
+			t.pos++
+		case '}':
+			t.pos++
+			return nil
+		default:
+			return t.errorf("expect ',' or '}'")
+		}
+	}
+}
+
+// array call elem for every element, elem must consume the value
+func (t *fastDecoderType) array(elem func() error) (err error) {
+	if t.null() {
+		return
+	}
+	if err = t.expect('['); err != nil {
+		return
+	}
+	if t.peek() == ']' {
+		t.pos++
+		return
+	}
+	for {
+		if err = elem(); err != nil {
+			return
+		}
+		switch t.peek() {
+		case ',':
+			t.pos++
+		case ']':
+			t.pos++
+			return
+		default:
+			return t.errorf("expect ',' or ']'")
+		}
+	}
+}
+
+// string return string value, null is returned as empty string
+func (t *fastDecoderType) string() (result string, err error) {
+	if t.null() {
+		return
+	}
+	if err = t.expect('"'); err != nil {
+		return
+	}
+	start := t.pos
+	for t.pos < len(t.data) {
+		switch t.data[t.pos] {
+		case '"':
+			result = string(t.data[start:t.pos])
+			t.pos++
+			return
+		case '\\':
+			return t.escapedString(start)
+		}
+		t.pos++
+	}
+	return "", t.errorf("unterminated string")
+}
+
+// escapedString continue reading string from start after the first backslash is found
+func (t *fastDecoderType) escapedString(start int) (result string, err error) {
+	buffer := append([]byte{}, t.data[start:t.pos]...)
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		switch {
+		case c == '"':
+			t.pos++
+			return string(buffer), nil
+		case c != '\\':
+			buffer = append(buffer, c)
+			t.pos++
+			continue
+		}
+		if t.pos+1 >= len(t.data) {
+			break
+		}
+		t.pos += 2
+		switch t.data[t.pos-1] {
+		case '"', '\\', '/':
+			buffer = append(buffer, t.data[t.pos-1])
+		case 'b':
+			buffer = append(buffer, '\b')
+		case 'f':
+			buffer = append(buffer, '\f')
+		case 'n':
+			buffer = append(buffer, '\n')
+		case 'r':
+			buffer = append(buffer, '\r')
+		case 't':
+			buffer = append(buffer, '\t')
+		case 'u':
+			r, err := t.hexRune()
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) {
+				low := rune(-1)
+				if t.pos+1 < len(t.data) && t.data[t.pos] == '\\' && t.data[t.pos+1] == 'u' {
+					t.pos += 2
+					if low, err = t.hexRune(); err != nil {
+						return "", err
+					}
+				}
+				r = utf16.DecodeRune(r, low)
+			}
+			buffer = append(buffer, string(r)...)
+		default:
+			return "", t.errorf("invalid escape")
+		}
+	}
+	return "", t.errorf("unterminated string")
+}
+
+func (t *fastDecoderType) hexRune() (rune, error) {
+	if t.pos+4 > len(t.data) {
+		return 0, t.errorf("invalid unicode escape")
+	}
+	value, err := strconv.ParseUint(string(t.data[t.pos:t.pos+4]), 16, 32)
+	if err != nil {
+		return 0, t.errorf("invalid unicode escape")
+	}
+	t.pos += 4
+	return rune(value), nil
+}
+
+// timestamp return timestamp of string value, null is returned as zero time
+func (t *fastDecoderType) timestamp() (result Timestamp, err error) {
+	if t.null() {
+		return
+	}
+	value, err := t.string()
+	if err != nil {
+		return
+	}
+	return parseTimestamp(value)
+}
+
+// int64 return integer value, null is returned as 0
+func (t *fastDecoderType) int64() (result int64, err error) {
+	if t.null() {
+		return
+	}
+	start := t.pos
+	if t.pos < len(t.data) && t.data[t.pos] == '-' {
+		t.pos++
+	}
+	for t.pos < len(t.data) && t.data[t.pos] >= '0' && t.data[t.pos] <= '9' {
+		t.pos++
+	}
+	if result, err = strconv.ParseInt(string(t.data[start:t.pos]), 10, 64); err != nil {
+		return 0, t.errorf("invalid integer")
+	}
+	return
+}
+
+// skip consume any value
+func (t *fastDecoderType) skip() (err error) {
+	switch t.peek() {
+	case '{':
+		return t.object(func(key string) error {
+			return t.skip()
+		})
+	case '[':
+		return t.array(t.skip)
+	case '"':
+		_, err = t.string()
+		return
+	}
+	// number, true, false or null
+	start := t.pos
+	for t.pos < len(t.data) {
+		switch t.data[t.pos] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			if t.pos == start {
+				return t.errorf("expect value")
+			}
+			return
+		}
+		t.pos++
+	}
+	if t.pos == start {
+		return t.errorf("expect value")
+	}
+	return
+}
+
+func (t *fastDecoderType) forum(forum *Forum) error {
+	return t.object(func(key string) (err error) {
+		switch key {
+		case "forumID":
+			forum.ForumID, err = t.string()
+		case "name":
+			forum.Name, err = t.string()
+		case "lorem":
+			forum.Lorem, err = t.string()
+		case "created":
+			forum.Created, err = t.timestamp()
+		case "threads":
+			err = t.array(func() error {
+				forum.Threads = append(forum.Threads, Thread{})
+				return t.thread(&forum.Threads[len(forum.Threads)-1])
+			})
+		case "threadCount":
+			forum.ThreadCount, err = t.int64()
+		default:
+			err = t.skip()
+		}
+		return
+	})
+}
+
+func (t *fastDecoderType) thread(thread *Thread) error {
+	return t.object(func(key string) (err error) {
+		switch key {
+		case "forumID":
+			thread.ForumID, err = t.string()
+		case "threadID":
+			thread.ThreadID, err = t.string()
+		case "name":
+			thread.Name, err = t.string()
+		case "lorem":
+			thread.Lorem, err = t.string()
+		case "created":
+			thread.Created, err = t.timestamp()
+		case "posts":
+			err = t.array(func() error {
+				thread.Posts = append(thread.Posts, Post{})
+				return t.post(&thread.Posts[len(thread.Posts)-1])
+			})
+		case "postCount":
+			thread.PostCount, err = t.int64()
+		default:
+			err = t.skip()
+		}
+		return
+	})
+}
+
+func (t *fastDecoderType) post(post *Post) error {
+	return t.object(func(key string) (err error) {
+		switch key {
+		case "threadID":
+			post.ThreadID, err = t.string()
+		case "postID":
+			post.PostID, err = t.string()
+		case "name":
+			post.Name, err = t.string()
+		case "lorem":
+			post.Lorem, err = t.string()
+		case "created":
+			post.Created, err = t.timestamp()
+		default:
+			err = t.skip()
+		}
+		return
+	})
+}
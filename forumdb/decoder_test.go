@@ -0,0 +1,112 @@
+package forumdb
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testForumJSON = `{
+	"forumID": "f1",
+	"name": "forum \"one\"",
+	"lorem": "line\nbreak é 😀 a\/b",
+	"created": "2018-09-04T12:00:00",
+	"threads": [
+		{
+			"forumID": "f1",
+			"threadID": "t1",
+			"name": null,
+			"lorem": "",
+			"created": "2018-09-04 12:00:00.000000",
+			"posts": [
+				{"threadID": "t1", "postID": "p1", "name": "p", "lorem": "l", "created": "2018-09-04T12:00:00"},
+				{"threadID": "t1", "postID": "p2", "name": "p", "lorem": "l", "created": "2018-09-04T12:00:00"}
+			]
+		},
+		{"forumID": "f1", "threadID": "t2", "name": "t", "lorem": "l", "created": "2018-09-04T12:00:00", "posts": null}
+	]
+}`
+
+func Test_decoders(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	expected := Forum{}
+	require.NoError(DefaultDecoder.Decode([]byte(testForumJSON), &expected))
+	assert.Equal("forum \"one\"", expected.Name)
+	assert.Equal("line\nbreak é 😀 a/b", expected.Lorem)
+	require.Len(expected.Threads, 2)
+	assert.Len(expected.Threads[0].Posts, 2)
+	// MySQL and PostgreSQL formats of the same time
+	assert.Equal(expected.Created, expected.Threads[0].Created)
+
+	for _, decoder := range Decoders {
+		forum := Forum{}
+		require.NoError(decoder.Decode([]byte(testForumJSON), &forum), decoder.Name)
+		assert.Equal(expected, forum, decoder.Name)
+	}
+
+	forum := Forum{}
+	require.NoError(decodeForumFast([]byte(`{"forumID": "f1", "extra": {"a": [1, true, null, "x"]}, "threads": []}`), &forum))
+	assert.Equal("f1", forum.ForumID)
+	assert.Error(decodeForumFast([]byte(`{"forumID": "f1"`), &forum))
+	assert.Error(decodeForumFast([]byte(`{"forumID": "f1"} x`), &forum))
+
+	_, err := DecoderByName("unknown")
+	assert.Error(err)
+	decoder, err := DecoderByName("fast")
+	require.NoError(err)
+	assert.Equal("fast", decoder.Name)
+}
+
+// fillFields set every exported field of value to a non-zero value, slices get one filled element
+func fillFields(t *testing.T, value reflect.Value, path string) {
+	if value.Type() == reflect.TypeOf(Timestamp{}) {
+		value.Set(reflect.ValueOf(Timestamp{Time: time.Date(2018, 9, 4, 12, 0, 0, 123456000, time.UTC)}))
+		return
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString("v-" + path)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		value.SetInt(int64(len(path)))
+	case reflect.Slice:
+		value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+		fillFields(t, value.Index(0), path+"[0]")
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" || strings.HasPrefix(field.Tag.Get("json"), "-") {
+				continue
+			}
+			fillFields(t, value.Field(i), path+"."+field.Name)
+		}
+	default:
+		t.Fatalf("%s: unsupported kind %s, teach fillFields and decodeForumFast", path, value.Kind())
+	}
+}
+
+func Test_decodeForumFastFields(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	expected := Forum{}
+	fillFields(t, reflect.ValueOf(&expected).Elem(), "forum")
+	require.Len(expected.Threads, 1)
+	require.Len(expected.Threads[0].Posts, 1)
+	data, err := json.Marshal(expected)
+	require.NoError(err)
+
+	forum := Forum{}
+	require.NoError(decodeForumFast(data, &forum))
+	assert.Equal(expected, forum, "fast decoder should decode every model field")
+}
@@ -0,0 +1,192 @@
+package forumdb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// flatRowType is one row of a flat join, thread and post columns are NULL
+// for a forum without threads or a thread without posts,
+// ThreadCount and PostCount are scanned only when an aggregate added them after forum and thread columns
+type flatRowType struct {
+	ForumID       string
+	ForumName     string
+	ForumLorem    string
+	ForumCreated  Timestamp
+	ThreadID      sql.NullString
+	ThreadName    sql.NullString
+	ThreadLorem   sql.NullString
+	ThreadCreated Timestamp
+	PostID        sql.NullString
+	PostName      sql.NullString
+	PostLorem     sql.NullString
+	PostCreated   Timestamp
+	ThreadCount   sql.NullInt64
+	PostCount     sql.NullInt64
+}
+
+func (t *flatRowType) scan(rows *sqlx.Rows, counts bool) error {
+	if counts {
+		return rows.Scan(
+			&t.ForumID, &t.ForumName, &t.ForumLorem, &t.ForumCreated, &t.ThreadCount,
+			&t.ThreadID, &t.ThreadName, &t.ThreadLorem, &t.ThreadCreated, &t.PostCount,
+			&t.PostID, &t.PostName, &t.PostLorem, &t.PostCreated,
+		)
+	}
+	return rows.Scan(
+		&t.ForumID, &t.ForumName, &t.ForumLorem, &t.ForumCreated,
+		&t.ThreadID, &t.ThreadName, &t.ThreadLorem, &t.ThreadCreated,
+		&t.PostID, &t.PostName, &t.PostLorem, &t.PostCreated,
+	)
+}
+
+// flatRowColumns is the column count of flatRowType without counts
+const flatRowColumns = 12
+
+// flatTreeType fold flat rows of any order into the nested tree
+type flatTreeType struct {
+	Forums []Forum
+	// forums map forumID to index of Forums
+	forums map[string]int
+	// threads map threadID to forum and thread index
+	threads map[string][2]int
+}
+
+func newFlatTree() *flatTreeType {
+	return &flatTreeType{
+		Forums:  []Forum{},
+		forums:  map[string]int{},
+		threads: map[string][2]int{},
+	}
+}
+
+func (t *flatTreeType) add(row flatRowType) {
+	fi, ok := t.forums[row.ForumID]
+	if !ok {
+		fi = len(t.Forums)
+		t.forums[row.ForumID] = fi
+		t.Forums = append(t.Forums, Forum{
+			ForumID:     row.ForumID,
+			Name:        row.ForumName,
+			Lorem:       row.ForumLorem,
+			Created:     row.ForumCreated,
+			ThreadCount: row.ThreadCount.Int64,
+		})
+	}
+	if !row.ThreadID.Valid {
+		return
+	}
+
+	index, ok := t.threads[row.ThreadID.String]
+	if !ok {
+		forum := &t.Forums[fi]
+		index = [2]int{fi, len(forum.Threads)}
+		t.threads[row.ThreadID.String] = index
+		forum.Threads = append(forum.Threads, Thread{
+			ForumID:   row.ForumID,
+			ThreadID:  row.ThreadID.String,
+			Name:      row.ThreadName.String,
+			Lorem:     row.ThreadLorem.String,
+			Created:   row.ThreadCreated,
+			PostCount: row.PostCount.Int64,
+		})
+	}
+	if !row.PostID.Valid {
+		return
+	}
+
+	thread := &t.Forums[index[0]].Threads[index[1]]
+	thread.Posts = append(thread.Posts, Post{
+		ThreadID: row.ThreadID.String,
+		PostID:   row.PostID.String,
+		Name:     row.PostName.String,
+		Lorem:    row.PostLorem.String,
+		Created:  row.PostCreated,
+	})
+}
+
+// selectDataFlatJoin load forums, threads and posts by one join query and assemble the tree in Go,
+// the query must return flatRowType columns and have forum, thread and post limit placeholders in order
+func selectDataFlatJoin(dialect Dialect, query string) func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+	return func(ctx context.Context, tx sqlx.QueryerContext, request Request) (result []Forum, err error) {
+		limit := request.Limit
+		pagedQuery, args, err := request.pageQuery(dialect, limitQuery(dialect, query, limit.Forums, limit.Threads, limit.Posts))
+		if err != nil {
+			return
+		}
+		tree := newFlatTree()
+		// columns is read from the first row only
+		columns := 0
+		if err = queryRows(ctx, tx, request.Phases, func(rows *sqlx.Rows) error {
+			if columns < 1 {
+				names, err := rows.Columns()
+				if err != nil {
+					return err
+				}
+				columns = len(names)
+			}
+			row := flatRowType{}
+			if err := row.scan(rows, columns > flatRowColumns); err != nil {
+				return err
+			}
+			tree.add(row)
+			return nil
+		}, pagedQuery, args...); err != nil {
+			return
+		}
+		if request.OnForum == nil {
+			return tree.Forums, nil
+		}
+		// rows of a forum are not adjacent, forums are complete only after the last row
+		result = []Forum{}
+		for i := range tree.Forums {
+			if result, err = request.emit(result, tree.Forums[i]); err != nil {
+				return
+			}
+			tree.Forums[i] = Forum{}
+		}
+		return
+	}
+}
+
+// selectMySQLDataFlatJoinQuery number rows by window functions, so flat-join needs MySQL 8.0,
+// user variable assignment order is not guaranteed and deprecated since 8.0
+const selectMySQLDataFlatJoinQuery = `
+SELECT f.forumID, f.name, f.lorem, f.created,
+	t.threadID, t.name, t.lorem, t.created,
+	p.postID, p.name, p.lorem, p.created
+FROM (
+	SELECT forumID, name, lorem, created
+	FROM forums f
+	LIMIT ?
+) f
+LEFT JOIN (
+	SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID) AS rnum
+	FROM threads
+) t ON t.forumID = f.forumID AND t.rnum <= ?
+LEFT JOIN (
+	SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID) AS rnum
+	FROM posts
+) p ON p.threadID = t.threadID AND p.rnum <= ?
+;`
+
+const selectPGSQLDataFlatJoinQuery = `
+SELECT f.forumID, f.name, f.lorem, f.created,
+	t.threadID, t.name, t.lorem, t.created,
+	p.postID, p.name, p.lorem, p.created
+FROM (
+	SELECT forumID, name, lorem, created
+	FROM forums f
+	LIMIT $1
+) f
+LEFT JOIN (
+	SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID) AS rnum
+	FROM threads
+) t ON t.forumID = f.forumID AND t.rnum <= $2
+LEFT JOIN (
+	SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID) AS rnum
+	FROM posts
+) p ON p.threadID = t.threadID AND p.rnum <= $3
+;`
@@ -0,0 +1,45 @@
+package forumdb
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_flatTree(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	row := func(forumID string, threadID string, postID string) flatRowType {
+		return flatRowType{
+			ForumID:  forumID,
+			ThreadID: sql.NullString{String: threadID, Valid: threadID != ""},
+			PostID:   sql.NullString{String: postID, Valid: postID != ""},
+		}
+	}
+
+	tree := newFlatTree()
+	// rows of a join are not ordered by forum or thread
+	tree.add(row("f1", "t1", "p1"))
+	tree.add(row("f2", "t3", "p4"))
+	tree.add(row("f1", "t2", ""))
+	tree.add(row("f1", "t1", "p2"))
+	tree.add(row("f3", "", ""))
+	tree.add(row("f2", "t3", "p5"))
+
+	require.Len(tree.Forums, 3)
+	assert.Equal("f1", tree.Forums[0].ForumID)
+	require.Len(tree.Forums[0].Threads, 2)
+	assert.Equal("f1", tree.Forums[0].Threads[0].ForumID)
+	require.Len(tree.Forums[0].Threads[0].Posts, 2)
+	assert.Equal("p2", tree.Forums[0].Threads[0].Posts[1].PostID)
+	assert.Equal("t1", tree.Forums[0].Threads[0].Posts[1].ThreadID)
+	assert.Empty(tree.Forums[0].Threads[1].Posts)
+	require.Len(tree.Forums[1].Threads, 1)
+	assert.Len(tree.Forums[1].Threads[0].Posts, 2)
+	assert.Empty(tree.Forums[2].Threads)
+}
@@ -0,0 +1,103 @@
+package forumdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IndexType is the kind of an index other than a plain btree index
+type IndexType string
+
+// supported index types
+const (
+	// IndexBTree is the default index of both dialects
+	IndexBTree IndexType = ""
+	// IndexFulltext is a MySQL FULLTEXT index, searched by MATCH ... AGAINST
+	IndexFulltext IndexType = "FULLTEXT"
+	// IndexGIN is a PostgreSQL GIN index, usually of a tsvector expression
+	IndexGIN IndexType = "GIN"
+)
+
+// Index is a secondary index a workload needs
+type Index struct {
+	Name  string
+	Table string
+	// Columns are indexed columns or expressions
+	Columns []string
+	// Dialect is the only dialect of index, empty for all dialects
+	Dialect Dialect
+	Type    IndexType
+}
+
+func (t Index) String() string {
+	return fmt.Sprintf("%s ON %s (%s)", t.Name, t.Table, strings.Join(t.Columns, ", "))
+}
+
+// createQuery return the statement creating index
+func (t Index) createQuery() string {
+	switch t.Type {
+	case IndexBTree:
+		return "CREATE INDEX " + t.String()
+	case IndexFulltext:
+		return "CREATE FULLTEXT INDEX " + t.String()
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s USING %s (%s)", t.Name, t.Table, t.Type, strings.Join(t.Columns, ", "))
+}
+
+// DialectIndexes return indexes of dialect
+func DialectIndexes(indexes []Index, dialect Dialect) (result []Index) {
+	for _, index := range indexes {
+		if index.Dialect == "" || index.Dialect == dialect {
+			result = append(result, index)
+		}
+	}
+	return
+}
+
+// Exists return true when index of the same name exists on table
+func (t Index) Exists(ctx context.Context, queryer sqlx.QueryerContext, dialect Dialect) (exists bool, err error) {
+	count := 0
+	switch dialect {
+	case MySQL:
+		err = sqlx.GetContext(ctx, queryer, &count, selectMySQLIndexCountQuery, t.Table, t.Name)
+	case PGSQL:
+		// unquoted identifiers are folded to lower case
+		err = sqlx.GetContext(ctx, queryer, &count, selectPGSQLIndexCountQuery, strings.ToLower(t.Table), strings.ToLower(t.Name))
+	default:
+		err = fmt.Errorf("unknown dialect %q", dialect)
+	}
+	return count > 0, err
+}
+
+// CreateIndexes create every index of dialect not existing yet, return created indexes
+func CreateIndexes(ctx context.Context, db sqlx.ExtContext, dialect Dialect, indexes []Index) (created []Index, err error) {
+	for _, index := range DialectIndexes(indexes, dialect) {
+		exists, err := index.Exists(ctx, db, dialect)
+		if err != nil {
+			return created, err
+		}
+		if exists {
+			continue
+		}
+		if _, err = db.ExecContext(ctx, index.createQuery()); err != nil {
+			return created, err
+		}
+		created = append(created, index)
+	}
+	return
+}
+
+const selectMySQLIndexCountQuery = `
+SELECT COUNT(*)
+FROM information_schema.statistics
+WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?
+;`
+
+const selectPGSQLIndexCountQuery = `
+SELECT COUNT(*)
+FROM pg_indexes
+WHERE schemaname = current_schema() AND tablename = $1 AND indexname = $2
+;`
@@ -0,0 +1,366 @@
+package forumdb
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LatestStrategies are latest activity strategies of all dialects, one for every strategy of Strategies,
+// loading forums ordered by their newest thread, each with its newest threads and newest posts of each thread,
+// forums without threads are last, Request.Page is not supported
+var LatestStrategies = []Strategy{
+	{
+		Dialect: MySQL,
+		Name:    "app-query",
+		Queries: []string{selectMySQLLatestForumsQuery, selectMySQLLatestThreadsQuery, selectMySQLLatestPostsQuery},
+		Select: withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			return selectDataAppQuery(ctx, tx, request, selectMySQLLatestForumsQuery, []interface{}{request.Limit.Forums}, selectMySQLLatestThreadsQuery, selectMySQLLatestPostsQuery)
+		}),
+	},
+	{
+		Dialect: MySQL,
+		Name:    "sub-query",
+		Queries: []string{selectMySQLLatestSubQuery},
+		Select: withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			limit := request.Limit
+			return selectData(ctx, tx, request, limitQuery(MySQL, selectMySQLLatestSubQuery, limit.Forums, limit.Posts, limit.Threads))
+		}),
+	},
+	{
+		Dialect: MySQL,
+		Name:    "flat-join",
+		Queries: []string{selectMySQLLatestFlatJoinQuery},
+		Select:  withoutPage(selectDataFlatJoin(MySQL, selectMySQLLatestFlatJoinQuery)),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "app-query",
+		Queries: []string{selectPGSQLLatestForumsQuery, selectPGSQLLatestThreadsQuery, selectPGSQLLatestPostsQuery},
+		Select: withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			return selectDataAppQuery(ctx, tx, request, selectPGSQLLatestForumsQuery, []interface{}{request.Limit.Forums}, selectPGSQLLatestThreadsQuery, selectPGSQLLatestPostsQuery)
+		}),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "sub-query",
+		Queries: []string{selectPGSQLLatestSubQuery},
+		Select:  withoutPage(selectPGSQLDataQuery(selectPGSQLLatestSubQuery)),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "lateral",
+		Queries: []string{selectPGSQLLatestLateralQuery},
+		Select:  withoutPage(selectPGSQLDataQuery(selectPGSQLLatestLateralQuery)),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "flat-join",
+		Queries: []string{selectPGSQLLatestFlatJoinQuery},
+		Select:  withoutPage(selectDataFlatJoin(PGSQL, selectPGSQLLatestFlatJoinQuery)),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "composite-array",
+		Queries: []string{selectPGSQLLatestCompositeArrayQuery},
+		Driver:  DriverPGX,
+		Select:  withoutPage(selectDataCompositeArrayQuery(selectPGSQLLatestCompositeArrayQuery)),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "lateral-pgx",
+		Queries: []string{selectPGSQLLatestLateralQuery},
+		Driver:  DriverPGX,
+		Select:  withoutPage(selectPGSQLDataQuery(selectPGSQLLatestLateralQuery)),
+	},
+}
+
+// DialectLatestStrategies return all latest activity strategies of dialect
+func DialectLatestStrategies(dialect Dialect) []Strategy {
+	return dialectStrategies(LatestStrategies, dialect)
+}
+
+// LatestIndexes are indexes latest activity strategies need to find newest threads of a forum and newest posts of a thread
+var LatestIndexes = []Index{
+	{Name: "threads_forumID_created_idx", Table: "threads", Columns: []string{"forumID", "created"}},
+	{Name: "posts_threadID_created_idx", Table: "posts", Columns: []string{"threadID", "created"}},
+}
+
+const selectMySQLLatestForumsQuery = `
+SELECT f.forumID AS "forumID", JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC, forumID
+	LIMIT ?
+) f
+ORDER BY f.activity DESC, f.forumID
+;`
+
+const selectPGSQLLatestForumsQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC NULLS LAST, forumID
+	LIMIT $1
+) f
+ORDER BY f.activity DESC NULLS LAST, f.forumID
+;`
+
+// selectMySQLLatestSubQuery number newest threads and posts by window functions like selectMySQLDataFlatJoinQuery
+const selectMySQLLatestSubQuery = `
+SELECT f.forumID, JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC, forumID
+	LIMIT ?
+) f
+LEFT JOIN (
+	SELECT t.forumID, CAST(CONCAT(
+		'[',
+		GROUP_CONCAT(
+			JSON_OBJECT(
+				'forumID', t.forumID,
+				'threadID', t.threadID,
+				'name', t.name,
+				'lorem', t.lorem,
+				'created', t.created,
+				'posts', p2.posts
+			)
+			ORDER BY t.created DESC, t.threadID
+		),
+		']'
+	) AS JSON) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID ORDER BY created DESC, threadID) AS rnum
+		FROM threads
+	) t
+	LEFT JOIN (
+		SELECT p.threadID, CAST(CONCAT(
+			'[',
+			GROUP_CONCAT(
+				JSON_OBJECT(
+					'threadID', p.threadID,
+					'postID', p.postID,
+					'name', p.name,
+					'lorem', p.lorem,
+					'created', p.created
+				)
+				ORDER BY p.created DESC, p.postID
+			),
+			']'
+		) AS JSON) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY created DESC, postID) AS rnum
+			FROM posts
+		) p
+		WHERE p.rnum <= ?
+		GROUP BY threadID
+	) p2 USING (threadID)
+	WHERE t.rnum <= ?
+	GROUP BY forumID
+) t USING (forumID)
+ORDER BY f.activity DESC, f.forumID
+;`
+
+const selectPGSQLLatestSubQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC NULLS LAST, forumID
+	LIMIT $1
+) f
+LEFT JOIN (
+	SELECT t.forumID, JSON_AGG(JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', p.posts
+	) ORDER BY t.created DESC, t.threadID) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID ORDER BY created DESC, threadID) AS rnum
+		FROM threads
+	) t
+	LEFT JOIN (
+		SELECT p.threadID, JSON_AGG(JSON_BUILD_OBJECT(
+			'threadID', p.threadID,
+			'postID', p.postID,
+			'name', p.name,
+			'lorem', p.lorem,
+			'created', p.created
+		) ORDER BY p.created DESC, p.postID) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY created DESC, postID) AS rnum
+			FROM posts
+		) p
+		WHERE p.rnum <= $3
+		GROUP BY threadID
+	) p USING (threadID)
+	WHERE t.rnum <= $2
+	GROUP BY forumID
+) t USING (forumID)
+ORDER BY f.activity DESC NULLS LAST, f.forumID
+;`
+
+const selectPGSQLLatestLateralQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t2.threads
+) AS data
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC NULLS LAST, forumID
+	LIMIT $1
+) f
+LEFT JOIN LATERAL (
+	SELECT JSON_AGG(JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', p2.posts
+	) ORDER BY t.created DESC, t.threadID) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created
+		FROM threads
+		WHERE threads.forumID = f.forumID
+		ORDER BY created DESC, threadID
+		LIMIT $2
+	) t
+	LEFT JOIN LATERAL (
+		SELECT JSON_AGG(JSON_BUILD_OBJECT(
+			'threadID', p.threadID,
+			'postID', p.postID,
+			'name', p.name,
+			'lorem', p.lorem,
+			'created', p.created
+		) ORDER BY p.created DESC, p.postID) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created
+			FROM posts
+			WHERE posts.threadID = t.threadID
+			ORDER BY created DESC, postID
+			LIMIT $3
+		) p
+	) p2
+	ON TRUE
+) t2
+ON TRUE
+ORDER BY f.activity DESC NULLS LAST, f.forumID
+;`
+
+// selectMySQLLatestFlatJoinQuery number newest threads and posts by window functions like selectMySQLDataFlatJoinQuery
+const selectMySQLLatestFlatJoinQuery = `
+SELECT f.forumID, f.name, f.lorem, f.created,
+	t.threadID, t.name, t.lorem, t.created,
+	p.postID, p.name, p.lorem, p.created
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC, forumID
+	LIMIT ?
+) f
+LEFT JOIN (
+	SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID ORDER BY created DESC, threadID) AS rnum
+	FROM threads
+) t ON t.forumID = f.forumID AND t.rnum <= ?
+LEFT JOIN (
+	SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY created DESC, postID) AS rnum
+	FROM posts
+) p ON p.threadID = t.threadID AND p.rnum <= ?
+ORDER BY f.activity DESC, f.forumID, t.created DESC, t.threadID, p.created DESC, p.postID
+;`
+
+const selectPGSQLLatestFlatJoinQuery = `
+SELECT f.forumID, f.name, f.lorem, f.created,
+	t.threadID, t.name, t.lorem, t.created,
+	p.postID, p.name, p.lorem, p.created
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC NULLS LAST, forumID
+	LIMIT $1
+) f
+LEFT JOIN (
+	SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID ORDER BY created DESC, threadID) AS rnum
+	FROM threads
+) t ON t.forumID = f.forumID AND t.rnum <= $2
+LEFT JOIN (
+	SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY created DESC, postID) AS rnum
+	FROM posts
+) p ON p.threadID = t.threadID AND p.rnum <= $3
+ORDER BY f.activity DESC NULLS LAST, f.forumID, t.created DESC, t.threadID, p.created DESC, p.postID
+;`
+
+const selectPGSQLLatestCompositeArrayQuery = `
+SELECT f.forumID, f.name, f.lorem, f.created, t2.threads
+FROM (
+	SELECT forumID, name, lorem, created,
+		(SELECT MAX(t.created) FROM threads t WHERE t.forumID = forums.forumID) AS activity
+	FROM forums
+	ORDER BY activity DESC NULLS LAST, forumID
+	LIMIT $1
+) f
+JOIN LATERAL (
+	SELECT ARRAY_AGG(ROW(t.forumID, t.threadID, t.name, t.lorem, t.created, p2.posts)::thread_type ORDER BY t.created DESC, t.threadID) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created
+		FROM threads
+		WHERE threads.forumID = f.forumID
+		ORDER BY created DESC, threadID
+		LIMIT $2
+	) t
+	JOIN LATERAL (
+		SELECT ARRAY_AGG(ROW(p.threadID, p.postID, p.name, p.lorem, p.created)::post_type ORDER BY p.created DESC, p.postID) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created
+			FROM posts
+			WHERE posts.threadID = t.threadID
+			ORDER BY created DESC, postID
+			LIMIT $3
+		) p
+	) p2
+	ON TRUE
+) t2
+ON TRUE
+ORDER BY f.activity DESC NULLS LAST, f.forumID
+;`
@@ -0,0 +1,40 @@
+package forumdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LatestStrategies(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	// every strategy implements the latest activity workload
+	for _, dialect := range []Dialect{MySQL, PGSQL} {
+		strategies, latest := DialectStrategies(dialect), DialectLatestStrategies(dialect)
+		require.Len(latest, len(strategies), dialect)
+		for i, strategy := range strategies {
+			assert.Equal(strategy.Name, latest[i].Name, dialect)
+			assert.Equal(strategy.Driver, latest[i].Driver, dialect)
+			for _, query := range latest[i].Queries {
+				assert.Contains(query, "ORDER BY", strategy.Name)
+				assert.False(strings.Contains(query, forumsSource), strategy.Name)
+				// user variable assignment order is not guaranteed, ties of created are broken by ID
+				assert.NotContains(query, ":=", strategy.Name)
+				assert.NotRegexp(`created DESC[^,]`, query, strategy.Name)
+			}
+		}
+	}
+
+	request := Request{Limit: DefaultLimit, Page: Page{Mode: PageOffset, Offset: 10}}
+	_, err := LatestStrategies[1].Select(context.Background(), nil, request)
+	assert.Error(err)
+
+	assert.Equal("threads_forumID_created_idx ON threads (forumID, created)", LatestIndexes[0].String())
+}
@@ -0,0 +1,92 @@
+// Package forumdb load the nested forum -> thread -> post tree from MySQL or PostgreSQL
+// with any benchmarked strategy, e.g.
+//
+//	loader, err := forumdb.NewLoader(forumdb.PGSQL, "lateral")
+//	forums, err := loader.Load(ctx, db, forumdb.DefaultLimit)
+package forumdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Loader load forums with one strategy and decoder
+type Loader struct {
+	Strategy Strategy
+	// Decoder decode JSON built by the database, default decoder when not set
+	Decoder Decoder
+}
+
+// NewLoader return loader of strategy name of dialect with default decoder
+func NewLoader(dialect Dialect, strategyName string) (loader Loader, err error) {
+	for _, strategy := range DialectStrategies(dialect) {
+		if strategy.Name == strategyName {
+			return Loader{Strategy: strategy, Decoder: DefaultDecoder}, nil
+		}
+	}
+	return loader, fmt.Errorf("unknown %s strategy %q", dialect, strategyName)
+}
+
+// NewLookupLoader return loader of point lookup strategy name of dialect with default decoder
+func NewLookupLoader(dialect Dialect, strategyName string) (loader Loader, err error) {
+	for _, strategy := range DialectLookupStrategies(dialect) {
+		if strategy.Name == strategyName {
+			return Loader{Strategy: strategy, Decoder: DefaultDecoder}, nil
+		}
+	}
+	return loader, fmt.Errorf("unknown %s lookup strategy %q", dialect, strategyName)
+}
+
+// NewSearchLoader return loader of search strategy name of dialect with default decoder
+func NewSearchLoader(dialect Dialect, strategyName string) (loader Loader, err error) {
+	for _, strategy := range DialectSearchStrategies(dialect) {
+		if strategy.Name == strategyName {
+			return Loader{Strategy: strategy, Decoder: DefaultDecoder}, nil
+		}
+	}
+	return loader, fmt.Errorf("unknown %s search strategy %q", dialect, strategyName)
+}
+
+// Load return at most limit forums with their threads and posts,
+// pass a transaction as queryer when the strategy issues more than one statement
+// and a consistent snapshot is required
+func (t Loader) Load(ctx context.Context, queryer sqlx.QueryerContext, limit Limit) ([]Forum, error) {
+	return t.Strategy.Select(ctx, queryer, Request{Limit: limit, Decoder: t.Decoder})
+}
+
+// LoadPage return forums of page with their threads and posts, limit.Forums is the page size,
+// e.g. the next keyset page is after the last returned forumID
+//
+//	forums, err := loader.LoadPage(ctx, db, limit, forumdb.Page{Mode: forumdb.PageKeyset, After: lastForumID})
+func (t Loader) LoadPage(ctx context.Context, queryer sqlx.QueryerContext, limit Limit, page Page) ([]Forum, error) {
+	return t.Strategy.Select(ctx, queryer, Request{Limit: limit, Page: page, Decoder: t.Decoder})
+}
+
+// Lookup return forum of forumID with its latest limit.Threads threads and latest limit.Posts posts of each thread,
+// loader must be of NewLookupLoader, sql.ErrNoRows when forum is not found
+func (t Loader) Lookup(ctx context.Context, queryer sqlx.QueryerContext, forumID string, limit Limit) (forum Forum, err error) {
+	forums, err := t.Strategy.Select(ctx, queryer, Request{Limit: limit, Decoder: t.Decoder, ForumID: forumID})
+	if err != nil {
+		return
+	}
+	if len(forums) < 1 {
+		return forum, sql.ErrNoRows
+	}
+	return forums[0], nil
+}
+
+// Search return at most limit.Forums forums with threads matching term or having posts matching term,
+// each with at most limit.Threads threads and limit.Posts matching posts, loader must be of NewSearchLoader
+func (t Loader) Search(ctx context.Context, queryer sqlx.QueryerContext, term string, limit Limit) ([]Forum, error) {
+	return t.Strategy.Select(ctx, queryer, Request{Limit: limit, Decoder: t.Decoder, Term: term})
+}
+
+// Stream pass every forum to fn as soon as it is complete without keeping loaded forums,
+// an error of fn stops loading and is returned
+func (t Loader) Stream(ctx context.Context, queryer sqlx.QueryerContext, limit Limit, fn func(forum Forum) error) (err error) {
+	_, err = t.Strategy.Select(ctx, queryer, Request{Limit: limit, Decoder: t.Decoder, OnForum: fn})
+	return
+}
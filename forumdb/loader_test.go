@@ -0,0 +1,77 @@
+package forumdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewLoader(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	loader, err := NewLoader(PGSQL, "lateral")
+	require.NoError(err)
+	assert.Equal(PGSQL, loader.Strategy.Dialect)
+	assert.Equal("lateral", loader.Strategy.Name)
+	assert.Equal(DefaultDecoder.Name, loader.Decoder.Name)
+
+	_, err = NewLoader(MySQL, "lateral")
+	assert.Error(err)
+
+	for _, strategy := range Strategies {
+		_, err = NewLoader(strategy.Dialect, strategy.Name)
+		assert.NoError(err, strategy.Name)
+	}
+}
+
+func Test_emit(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	result, err := Request{}.emit(nil, Forum{ForumID: "f1"})
+	require.NoError(err)
+	assert.Len(result, 1)
+
+	streamed := []string{}
+	phases := &Phases{}
+	request := Request{Phases: phases, OnForum: func(forum Forum) error {
+		streamed = append(streamed, forum.ForumID)
+		time.Sleep(time.Millisecond)
+		return nil
+	}}
+	start, skipped := time.Now(), phases.skippedTime()
+	result, err = request.emit(nil, Forum{ForumID: "f1"})
+	require.NoError(err)
+	phases.addAssemble(start, skipped)
+	assert.Empty(result)
+	assert.Equal([]string{"f1"}, streamed)
+	// time spent in OnForum is not assembling
+	assert.True(phases.Assemble < time.Millisecond, phases.Assemble)
+}
+
+func Test_NewLookupLoader(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	loader, err := NewLookupLoader(MySQL, "sub-query")
+	require.NoError(err)
+	assert.Equal(MySQL, loader.Strategy.Dialect)
+	assert.Contains(loader.Strategy.Queries[0], "WHERE f.forumID = ?")
+
+	_, err = NewLookupLoader(MySQL, "lateral")
+	assert.Error(err)
+
+	for _, strategy := range LookupStrategies {
+		_, err = NewLookupLoader(strategy.Dialect, strategy.Name)
+		assert.NoError(err, strategy.Name)
+	}
+}
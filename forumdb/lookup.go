@@ -0,0 +1,286 @@
+package forumdb
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LookupStrategies are point lookup strategies of all dialects,
+// loading the forum of Request.ForumID with its latest threads and latest posts of each thread
+var LookupStrategies = []Strategy{
+	{
+		Dialect: MySQL,
+		Name:    "app-query",
+		Queries: []string{selectMySQLLookupForumQuery, selectMySQLLatestThreadsQuery, selectMySQLLatestPostsQuery},
+		Select: withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			return selectDataAppQuery(ctx, tx, request, selectMySQLLookupForumQuery, []interface{}{request.ForumID}, selectMySQLLatestThreadsQuery, selectMySQLLatestPostsQuery)
+		}),
+	},
+	{
+		Dialect: MySQL,
+		Name:    "sub-query",
+		Queries: []string{selectMySQLLookupSubQuery},
+		Select: withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			limit := request.Limit
+			return selectData(ctx, tx, request, selectMySQLLookupSubQuery, request.ForumID, limit.Threads, request.ForumID, limit.Posts, request.ForumID)
+		}),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "app-query",
+		Queries: []string{selectPGSQLLookupForumQuery, selectPGSQLLatestThreadsQuery, selectPGSQLLatestPostsQuery},
+		Select: withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			return selectDataAppQuery(ctx, tx, request, selectPGSQLLookupForumQuery, []interface{}{request.ForumID}, selectPGSQLLatestThreadsQuery, selectPGSQLLatestPostsQuery)
+		}),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "sub-query",
+		Queries: []string{selectPGSQLLookupSubQuery},
+		Select:  withoutPage(selectPGSQLLookupQuery(selectPGSQLLookupSubQuery)),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "lateral",
+		Queries: []string{selectPGSQLLookupLateralQuery},
+		Select:  withoutPage(selectPGSQLLookupQuery(selectPGSQLLookupLateralQuery)),
+	},
+}
+
+// DialectLookupStrategies return all point lookup strategies of dialect
+func DialectLookupStrategies(dialect Dialect) []Strategy {
+	return dialectStrategies(LookupStrategies, dialect)
+}
+
+// selectPGSQLLookupQuery return Select of query with forumID, thread and post limits as $1, $2 and $3
+func selectPGSQLLookupQuery(query string) func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+	return func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+		limit := request.Limit
+		return selectData(ctx, tx, request, query, request.ForumID, limit.Threads, limit.Posts)
+	}
+}
+
+const selectMySQLLookupForumQuery = `
+SELECT f.forumID AS "forumID", JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM forums f
+WHERE f.forumID = ?
+;`
+
+const selectMySQLLatestThreadsQuery = `
+SELECT t.forumID AS "forumID",
+	t.threadID AS "threadID",
+	t.name,
+	t.lorem,
+	t.created
+FROM threads t
+WHERE forumID = ?
+ORDER BY created DESC, threadID
+LIMIT ?
+;`
+
+const selectMySQLLatestPostsQuery = `
+SELECT p.threadID AS "threadID",
+	p.postID AS "postID",
+	p.name,
+	p.lorem,
+	p.created
+FROM posts p
+WHERE threadID = ?
+ORDER BY created DESC, postID
+LIMIT ?
+;`
+
+const selectPGSQLLookupForumQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM forums f
+WHERE f.forumID = $1
+;`
+
+const selectPGSQLLatestThreadsQuery = `
+SELECT t.forumID AS "forumID",
+	t.threadID AS "threadID",
+	t.name,
+	t.lorem,
+	t.created
+FROM threads t
+WHERE forumID = $1
+ORDER BY created DESC, threadID
+LIMIT $2
+;`
+
+const selectPGSQLLatestPostsQuery = `
+SELECT p.threadID AS "threadID",
+	p.postID AS "postID",
+	p.name,
+	p.lorem,
+	p.created
+FROM posts p
+WHERE threadID = $1
+ORDER BY created DESC, postID
+LIMIT $2
+;`
+
+// selectMySQLLookupSubQuery number latest posts by window functions like selectMySQLDataFlatJoinQuery
+const selectMySQLLookupSubQuery = `
+SELECT f.forumID, JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM forums f
+LEFT JOIN (
+	SELECT t.forumID, CAST(CONCAT(
+		'[',
+		GROUP_CONCAT(
+			JSON_OBJECT(
+				'forumID', t.forumID,
+				'threadID', t.threadID,
+				'name', t.name,
+				'lorem', t.lorem,
+				'created', t.created,
+				'posts', p2.posts
+			)
+			ORDER BY t.created DESC, t.threadID
+		),
+		']'
+	) AS JSON) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created
+		FROM threads
+		WHERE forumID = ?
+		ORDER BY created DESC, threadID
+		LIMIT ?
+	) t
+	LEFT JOIN (
+		SELECT p.threadID, CAST(CONCAT(
+			'[',
+			GROUP_CONCAT(
+				JSON_OBJECT(
+					'threadID', p.threadID,
+					'postID', p.postID,
+					'name', p.name,
+					'lorem', p.lorem,
+					'created', p.created
+				)
+				ORDER BY p.created DESC, p.postID
+			),
+			']'
+		) AS JSON) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY created DESC, postID) AS rnum
+			FROM posts
+			WHERE threadID IN (SELECT threadID FROM threads WHERE forumID = ?)
+		) p
+		WHERE p.rnum <= ?
+		GROUP BY threadID
+	) p2 USING (threadID)
+	GROUP BY forumID
+) t USING (forumID)
+WHERE f.forumID = ?
+;`
+
+const selectPGSQLLookupSubQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM forums f
+LEFT JOIN (
+	SELECT t.forumID, JSON_AGG(JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', p.posts
+	) ORDER BY t.created DESC, t.threadID) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created
+		FROM threads
+		WHERE forumID = $1
+		ORDER BY created DESC, threadID
+		LIMIT $2
+	) t
+	LEFT JOIN (
+		SELECT p.threadID, JSON_AGG(JSON_BUILD_OBJECT(
+			'threadID', p.threadID,
+			'postID', p.postID,
+			'name', p.name,
+			'lorem', p.lorem,
+			'created', p.created
+		) ORDER BY p.created DESC, p.postID) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY created DESC, postID) AS rnum
+			FROM posts
+			WHERE threadID IN (SELECT threadID FROM threads WHERE forumID = $1)
+		) p
+		WHERE p.rnum <= $3
+		GROUP BY threadID
+	) p USING (threadID)
+	GROUP BY forumID
+) t USING (forumID)
+WHERE f.forumID = $1
+;`
+
+const selectPGSQLLookupLateralQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t2.threads
+) AS data
+FROM forums f
+LEFT JOIN LATERAL (
+	SELECT JSON_AGG(JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', p2.posts
+	) ORDER BY t.created DESC, t.threadID) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created
+		FROM threads
+		WHERE threads.forumID = f.forumID
+		ORDER BY created DESC, threadID
+		LIMIT $2
+	) t
+	LEFT JOIN LATERAL (
+		SELECT JSON_AGG(JSON_BUILD_OBJECT(
+			'threadID', p.threadID,
+			'postID', p.postID,
+			'name', p.name,
+			'lorem', p.lorem,
+			'created', p.created
+		) ORDER BY p.created DESC, p.postID) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created
+			FROM posts
+			WHERE posts.threadID = t.threadID
+			ORDER BY created DESC, postID
+			LIMIT $3
+		) p
+	) p2
+	ON TRUE
+) t2
+ON TRUE
+WHERE f.forumID = $1
+;`
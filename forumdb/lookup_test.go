@@ -0,0 +1,23 @@
+package forumdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LookupStrategies(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	for _, strategy := range LookupStrategies {
+		for _, query := range strategy.Queries {
+			// user variable assignment order is not guaranteed, ties of created are broken by ID
+			assert.NotContains(query, ":=", strategy.Name)
+			assert.NotRegexp(`created DESC[^,]`, query, strategy.Name)
+		}
+	}
+}
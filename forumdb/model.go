@@ -0,0 +1,42 @@
+package forumdb
+
+import (
+	"github.com/tsaikd/KDGoLib/sqlutil"
+)
+
+// Forum is a forum with its latest threads
+type Forum struct {
+	ForumID string    `db:"forumID" json:"forumID"`
+	Name    string    `db:"name" json:"name"`
+	Lorem   string    `db:"lorem" json:"lorem"`
+	Created Timestamp `db:"created" json:"created"`
+	Threads []Thread  `db:"threads" json:"threads"`
+	// ThreadCount is the total thread count of forum, loaded only by strategies with an aggregate
+	ThreadCount int64 `db:"threadCount" json:"threadCount,omitempty"`
+}
+
+// Thread is a thread with its latest posts
+type Thread struct {
+	ForumID  string    `db:"forumID" json:"forumID"`
+	ThreadID string    `db:"threadID" json:"threadID"`
+	Name     string    `db:"name" json:"name"`
+	Lorem    string    `db:"lorem" json:"lorem"`
+	Created  Timestamp `db:"created" json:"created"`
+	Posts    []Post    `db:"posts" json:"posts"`
+	// PostCount is the total post count of thread, loaded only by strategies with an aggregate
+	PostCount int64 `db:"postCount" json:"postCount,omitempty"`
+}
+
+// Post is a post of a thread
+type Post struct {
+	ThreadID string    `db:"threadID" json:"threadID"`
+	PostID   string    `db:"postID" json:"postID"`
+	Name     string    `db:"name" json:"name"`
+	Lorem    string    `db:"lorem" json:"lorem"`
+	Created  Timestamp `db:"created" json:"created"`
+}
+
+// Scan decode SQL json value
+func (t *Forum) Scan(value interface{}) (err error) {
+	return sqlutil.SQLScanStrictJSON(t, value)
+}
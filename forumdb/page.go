@@ -0,0 +1,153 @@
+package forumdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PageMode is how a Request select its page of forums
+type PageMode string
+
+// supported page modes
+const (
+	// PageNone load the first forums in no specific order
+	PageNone PageMode = ""
+	// PageKeyset load forums after a forumID cursor in forumID order
+	PageKeyset PageMode = "keyset"
+	// PageOffset load forums after skipping Offset forums in forumID order
+	PageOffset PageMode = "offset"
+)
+
+// PageModes are all page modes except PageNone
+var PageModes = []PageMode{PageKeyset, PageOffset}
+
+// PageModeByName return page mode of name
+func PageModeByName(name string) (PageMode, error) {
+	for _, mode := range PageModes {
+		if string(mode) == name {
+			return mode, nil
+		}
+	}
+	return PageNone, fmt.Errorf("unknown page mode %q", name)
+}
+
+// Page select which forums a Request loads, Limit.Forums is the page size
+type Page struct {
+	Mode PageMode
+	// After is the last forumID of the previous page in keyset mode, empty for the first page
+	After string
+	// Offset is the count of skipped forums in offset mode
+	Offset int
+}
+
+// NewPage return page number depth, counted from 0, of size forums in mode,
+// keyset cursor is the last forumID of the previous page
+func NewPage(ctx context.Context, tx sqlx.QueryerContext, dialect Dialect, mode PageMode, depth int, size int) (page Page, err error) {
+	page = Page{Mode: mode}
+	switch mode {
+	case PageNone:
+	case PageOffset:
+		page.Offset = depth * size
+	case PageKeyset:
+		if depth < 1 {
+			return
+		}
+		query := selectMySQLPageCursorQuery
+		if dialect == PGSQL {
+			query = selectPGSQLPageCursorQuery
+		}
+		if err = sqlx.GetContext(ctx, tx, &page.After, query, depth*size-1); err == sql.ErrNoRows {
+			err = fmt.Errorf("page %d of %d forums is beyond the data", depth, size)
+		}
+	default:
+		err = fmt.Errorf("unknown page mode %q", mode)
+	}
+	return
+}
+
+// withoutPage return select failing on requests with a page, for strategies not supporting pages
+func withoutPage(selectFn func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error)) func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+	return func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+		if request.Page.Mode != PageNone {
+			return nil, fmt.Errorf("strategy doesn't support pages")
+		}
+		return selectFn(ctx, tx, request)
+	}
+}
+
+// forumsSource is the forums source of every strategy query, replaced by the page of forums
+const forumsSource = "FROM forums f"
+
+type pageQueryKeyType struct {
+	Dialect Dialect
+	Mode    PageMode
+	Query   string
+	Args    int
+}
+
+// pageQueries cache paged query of every query, so iterations don't rebuild query text
+var pageQueries = sync.Map{}
+
+// pageQuery return query and args loading the page of request instead of the first forums,
+// page arguments go first for MySQL positional placeholders,
+// and after args as numbered placeholders for PostgreSQL
+func (t Request) pageQuery(dialect Dialect, query string, args ...interface{}) (string, []interface{}, error) {
+	page := t.Page
+	var pageArgs []interface{}
+	switch page.Mode {
+	case PageNone:
+		return query, args, nil
+	case PageKeyset:
+		pageArgs = []interface{}{page.After, t.Limit.Forums}
+	case PageOffset:
+		pageArgs = []interface{}{t.Limit.Forums, page.Offset}
+	default:
+		return "", nil, fmt.Errorf("unknown page mode %q", page.Mode)
+	}
+
+	key := pageQueryKeyType{Dialect: dialect, Mode: page.Mode, Query: query, Args: len(args)}
+	paged, ok := pageQueries.Load(key)
+	if !ok {
+		if !strings.Contains(query, forumsSource) {
+			return "", nil, fmt.Errorf("query has no %q to page, strategy doesn't support pages", forumsSource)
+		}
+		paged = strings.Replace(query, forumsSource, "FROM ("+pageSource(dialect, page.Mode, len(args))+") f", 1)
+		pageQueries.Store(key, paged)
+	}
+	if dialect == MySQL {
+		return paged.(string), append(pageArgs, args...), nil
+	}
+	return paged.(string), append(append([]interface{}{}, args...), pageArgs...), nil
+}
+
+// pageSource return the subquery selecting the page of forums,
+// PostgreSQL placeholders start after argCount arguments of the query
+func pageSource(dialect Dialect, mode PageMode, argCount int) string {
+	first, second := "?", "?"
+	if dialect == PGSQL {
+		first, second = fmt.Sprintf("$%d", argCount+1), fmt.Sprintf("$%d", argCount+2)
+	}
+	if mode == PageKeyset {
+		return "SELECT * FROM forums WHERE forumID > " + first + " ORDER BY forumID LIMIT " + second
+	}
+	return "SELECT * FROM forums ORDER BY forumID LIMIT " + first + " OFFSET " + second
+}
+
+const selectMySQLPageCursorQuery = `
+SELECT forumID
+FROM forums
+ORDER BY forumID
+LIMIT 1 OFFSET ?
+;`
+
+const selectPGSQLPageCursorQuery = `
+SELECT forumID
+FROM forums
+ORDER BY forumID
+LIMIT 1 OFFSET $1
+;`
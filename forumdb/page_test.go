@@ -0,0 +1,63 @@
+package forumdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pageQuery(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	limit := Limit{Forums: 10, Threads: 5, Posts: 3}
+
+	query, args, err := Request{Limit: limit}.pageQuery(PGSQL, selectPGSQLForumsQuery, 10)
+	require.NoError(err)
+	assert.Equal(selectPGSQLForumsQuery, query)
+	assert.Equal([]interface{}{10}, args)
+
+	request := Request{Limit: limit, Page: Page{Mode: PageKeyset, After: "f9"}}
+	query, args, err = request.pageQuery(PGSQL, selectPGSQLForumsQuery, 10)
+	require.NoError(err)
+	assert.Contains(query, "FROM (SELECT * FROM forums WHERE forumID > $2 ORDER BY forumID LIMIT $3) f")
+	assert.Equal([]interface{}{10, "f9", 10}, args)
+
+	query, args, err = request.pageQuery(MySQL, limitQuery(MySQL, selectMySQLDataSubQuery, limit.Posts, limit.Threads, limit.Forums))
+	require.NoError(err)
+	assert.Contains(query, "FROM (SELECT * FROM forums WHERE forumID > ? ORDER BY forumID LIMIT ?) f")
+	assert.Equal([]interface{}{"f9", 10}, args)
+
+	request.Page = Page{Mode: PageOffset, Offset: 20}
+	query, args, err = request.pageQuery(PGSQL, limitQuery(PGSQL, selectPGSQLDataLateralQuery, limit.Forums, limit.Threads, limit.Posts))
+	require.NoError(err)
+	assert.Contains(query, "FROM (SELECT * FROM forums ORDER BY forumID LIMIT $1 OFFSET $2) f")
+	assert.Equal([]interface{}{10, 20}, args)
+
+	request.Page = Page{Mode: "unknown"}
+	_, _, err = request.pageQuery(PGSQL, selectPGSQLForumsQuery, 10)
+	assert.Error(err)
+
+	// every strategy pages its forums by the first statement
+	for _, strategy := range Strategies {
+		assert.Equal(1, strings.Count(strategy.Queries[0], forumsSource), strategy.Dialect, strategy.Name)
+	}
+}
+
+func Test_PageModeByName(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	mode, err := PageModeByName("keyset")
+	require.NoError(err)
+	assert.Equal(PageKeyset, mode)
+
+	_, err = PageModeByName("cursor")
+	assert.Error(err)
+}
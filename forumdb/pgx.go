@@ -0,0 +1,316 @@
+package forumdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/jmoiron/sqlx"
+)
+
+// DriverPGX is the database/sql driver name of pgx stdlib
+const DriverPGX = "pgx"
+
+// PGXQueryer run query on the native pgx connection with binary result format
+// and call fn with the rows, rows are closed after fn returns
+type PGXQueryer interface {
+	QueryPGX(ctx context.Context, fn func(rows pgx.Rows) error, query string, args ...interface{}) error
+}
+
+// PGXRecorder is implemented by driver connections wrapping pgx stdlib to count native queries bypassing them
+type PGXRecorder interface {
+	// RecordPGXQuery record a native query sent at start
+	RecordPGXQuery(start time.Time)
+	// RecordPGXFetch record one rows.Next call from start, values are raw values of the fetched row, nil after the last row
+	RecordPGXFetch(start time.Time, values [][]byte)
+}
+
+// PGXTx is a transaction on one connection of a pgx stdlib db,
+// native queries of QueryPGX run in the same transaction
+type PGXTx struct {
+	*sqlx.Tx
+	conn *sqlx.Conn
+}
+
+// BeginPGXTx begin transaction on db opened with DriverPGX
+func BeginPGXTx(ctx context.Context, db *sqlx.DB, opts *sql.TxOptions) (result *PGXTx, err error) {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return
+	}
+	tx, err := conn.BeginTxx(ctx, opts)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	return &PGXTx{Tx: tx, conn: conn}, nil
+}
+
+// Commit commit transaction and release connection
+func (t *PGXTx) Commit() error {
+	defer t.conn.Close()
+	return t.Tx.Commit()
+}
+
+// Rollback abort transaction and release connection
+func (t *PGXTx) Rollback() error {
+	defer t.conn.Close()
+	return t.Tx.Rollback()
+}
+
+// QueryPGX implement PGXQueryer, native queries bypass wrappers of the database/sql driver
+// and are passed to the first wrapper implementing PGXRecorder
+func (t *PGXTx) QueryPGX(ctx context.Context, fn func(rows pgx.Rows) error, query string, args ...interface{}) error {
+	return t.conn.Raw(func(driverConn interface{}) (err error) {
+		conn, recorder, err := nativePGXConn(driverConn)
+		if err != nil {
+			return
+		}
+		start := time.Now()
+		rows, err := conn.Query(ctx, query, append([]interface{}{pgx.QueryResultFormats{pgx.BinaryFormatCode}}, args...)...)
+		if recorder != nil {
+			recorder.RecordPGXQuery(start)
+		}
+		if err != nil {
+			return
+		}
+		if recorder != nil {
+			rows = &recordedPGXRowsType{Rows: rows, recorder: recorder}
+		}
+		defer rows.Close()
+		if err = fn(rows); err != nil {
+			return
+		}
+		rows.Close()
+		return rows.Err()
+	})
+}
+
+// nativePGXConn return pgx connection of pgx stdlib driver connection and the outermost PGXRecorder wrapping it,
+// wrapping driver connections must implement Unwrap
+func nativePGXConn(driverConn interface{}) (conn *pgx.Conn, recorder PGXRecorder, err error) {
+	for {
+		if wrapper, ok := driverConn.(PGXRecorder); ok && recorder == nil {
+			recorder = wrapper
+		}
+		switch c := driverConn.(type) {
+		case interface{ Conn() *pgx.Conn }:
+			return c.Conn(), recorder, nil
+		case interface{ Unwrap() driver.Conn }:
+			driverConn = c.Unwrap()
+		default:
+			return nil, nil, fmt.Errorf("driver connection %T is not pgx", driverConn)
+		}
+	}
+}
+
+// recordedPGXRowsType pass every fetched row to recorder
+type recordedPGXRowsType struct {
+	pgx.Rows
+	recorder PGXRecorder
+}
+
+func (t *recordedPGXRowsType) Next() bool {
+	start := time.Now()
+	if !t.Rows.Next() {
+		t.recorder.RecordPGXFetch(start, nil)
+		return false
+	}
+	values := t.Rows.RawValues()
+	if values == nil {
+		values = [][]byte{}
+	}
+	t.recorder.RecordPGXFetch(start, values)
+	return true
+}
+
+// selectDataCompositeArray load forums with threads and posts as binary arrays of composite types
+func selectDataCompositeArray(ctx context.Context, tx sqlx.QueryerContext, request Request) (result []Forum, err error) {
+	return selectDataCompositeArrayQuery(selectPGSQLDataCompositeArrayQuery)(ctx, tx, request)
+}
+
+// selectDataCompositeArrayQuery return Select of query returning forum columns and thread_type array,
+// with forum, thread and post limits as $1, $2 and $3
+func selectDataCompositeArrayQuery(query string) func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+	return func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+		limit := request.Limit
+		pagedQuery, args, err := request.pageQuery(PGSQL, query, limit.Forums, limit.Threads, limit.Posts)
+		if err != nil {
+			return nil, err
+		}
+		return selectDataCompositeArrayRows(ctx, tx, request, pagedQuery, args...)
+	}
+}
+
+// selectDataCompositeArrayRows run query on the native pgx connection of tx and decode forums
+func selectDataCompositeArrayRows(ctx context.Context, tx sqlx.QueryerContext, request Request, query string, args ...interface{}) (result []Forum, err error) {
+	queryer, ok := tx.(PGXQueryer)
+	if !ok {
+		return nil, fmt.Errorf("composite-array strategy needs a pgx transaction, got %T", tx)
+	}
+
+	phases := request.Phases
+	result = []Forum{}
+	err = queryer.QueryPGX(ctx, func(rows pgx.Rows) (err error) {
+		start := time.Now()
+		first := true
+		for {
+			hasNext := rows.Next()
+			phases.addFetch(start, first)
+			first = false
+			if !hasNext {
+				return
+			}
+
+			scanStart := time.Now()
+			forumID, name, lorem, created := pgtype.Text{}, pgtype.Text{}, pgtype.Text{}, pgtype.Timestamp{}
+			threads := pgxThreadsType{}
+			if err = rows.Scan(&forumID, &name, &lorem, &created, &threads); err != nil {
+				return
+			}
+			forum := Forum{
+				ForumID: forumID.String,
+				Name:    name.String,
+				Lorem:   lorem.String,
+				Created: pgxTimestamp(created),
+				Threads: threads,
+			}
+			phases.addDecode(scanStart)
+			if result, err = request.emit(result, forum); err != nil {
+				return
+			}
+			start = time.Now()
+		}
+	}, query, args...)
+	return
+}
+
+func pgxTimestamp(value pgtype.Timestamp) Timestamp {
+	if value.Status != pgtype.Present {
+		return Timestamp{}
+	}
+	return Timestamp{Time: value.Time.UTC()}
+}
+
+// decodeCompositeArray call decode for every non-null element of one dimension binary array of composite values
+func decodeCompositeArray(ci *pgtype.ConnInfo, src []byte, decode func(fields *pgtype.CompositeBinaryScanner) error) (err error) {
+	if src == nil {
+		return
+	}
+	header := pgtype.ArrayHeader{}
+	rp, err := header.DecodeBinary(ci, src)
+	if err != nil {
+		return
+	}
+	if len(header.Dimensions) < 1 {
+		return
+	}
+	if len(header.Dimensions) > 1 {
+		return fmt.Errorf("composite array of %d dimensions is not supported", len(header.Dimensions))
+	}
+	for i := int32(0); i < header.Dimensions[0].Length; i++ {
+		if len(src[rp:]) < 4 {
+			return fmt.Errorf("composite array incomplete")
+		}
+		elemLen := int(int32(binary.BigEndian.Uint32(src[rp:])))
+		rp += 4
+		if elemLen < 0 {
+			continue
+		}
+		if len(src[rp:]) < elemLen {
+			return fmt.Errorf("composite array incomplete")
+		}
+		fields := pgtype.NewCompositeBinaryScanner(ci, src[rp:rp+elemLen])
+		rp += elemLen
+		if err = decode(fields); err != nil {
+			return
+		}
+		if err = fields.Err(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// pgxThreadsType decode binary thread_type[]
+type pgxThreadsType []Thread
+
+// DecodeBinary implement pgtype.BinaryDecoder
+func (t *pgxThreadsType) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	*t = nil
+	return decodeCompositeArray(ci, src, func(fields *pgtype.CompositeBinaryScanner) error {
+		forumID, threadID, name, lorem, created := pgtype.Text{}, pgtype.Text{}, pgtype.Text{}, pgtype.Text{}, pgtype.Timestamp{}
+		posts := pgxPostsType{}
+		fields.ScanDecoder(&forumID)
+		fields.ScanDecoder(&threadID)
+		fields.ScanDecoder(&name)
+		fields.ScanDecoder(&lorem)
+		fields.ScanDecoder(&created)
+		fields.ScanDecoder(&posts)
+		*t = append(*t, Thread{
+			ForumID:  forumID.String,
+			ThreadID: threadID.String,
+			Name:     name.String,
+			Lorem:    lorem.String,
+			Created:  pgxTimestamp(created),
+			Posts:    posts,
+		})
+		return nil
+	})
+}
+
+// pgxPostsType decode binary post_type[]
+type pgxPostsType []Post
+
+// DecodeBinary implement pgtype.BinaryDecoder
+func (t *pgxPostsType) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	*t = nil
+	return decodeCompositeArray(ci, src, func(fields *pgtype.CompositeBinaryScanner) error {
+		threadID, postID, name, lorem, created := pgtype.Text{}, pgtype.Text{}, pgtype.Text{}, pgtype.Text{}, pgtype.Timestamp{}
+		fields.ScanDecoder(&threadID)
+		fields.ScanDecoder(&postID)
+		fields.ScanDecoder(&name)
+		fields.ScanDecoder(&lorem)
+		fields.ScanDecoder(&created)
+		*t = append(*t, Post{
+			ThreadID: threadID.String,
+			PostID:   postID.String,
+			Name:     name.String,
+			Lorem:    lorem.String,
+			Created:  pgxTimestamp(created),
+		})
+		return nil
+	})
+}
+
+// selectPGSQLDataCompositeArrayQuery needs thread_type and post_type of create_type_pgsql.sql
+const selectPGSQLDataCompositeArrayQuery = `
+SELECT f.forumID, f.name, f.lorem, f.created, t2.threads
+FROM forums f
+JOIN LATERAL (
+	SELECT ARRAY_AGG(ROW(t.forumID, t.threadID, t.name, t.lorem, t.created, p2.posts)::thread_type) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created
+		FROM threads
+		WHERE threads.forumID = f.forumID
+		LIMIT $2
+	) t
+	JOIN LATERAL (
+		SELECT ARRAY_AGG(ROW(p.threadID, p.postID, p.name, p.lorem, p.created)::post_type) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created
+			FROM posts
+			WHERE posts.threadID = t.threadID
+			LIMIT $3
+		) p
+	) p2
+	ON TRUE
+) t2
+ON TRUE
+LIMIT $1
+;`
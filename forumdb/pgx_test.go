@@ -0,0 +1,170 @@
+package forumdb
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawBinaryType encode already encoded bytes
+type rawBinaryType []byte
+
+func (t rawBinaryType) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return append(buf, t...), nil
+}
+
+// testCompositeArray encode one dimension binary array, nil elements are NULL
+func testCompositeArray(ci *pgtype.ConnInfo, elems ...[]byte) []byte {
+	header := pgtype.ArrayHeader{Dimensions: []pgtype.ArrayDimension{{Length: int32(len(elems)), LowerBound: 1}}}
+	buf := header.EncodeBinary(ci, nil)
+	for _, elem := range elems {
+		if elem == nil {
+			buf = append(buf, 0xff, 0xff, 0xff, 0xff)
+			continue
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(elem)))
+		buf = append(buf, elem...)
+	}
+	return buf
+}
+
+func Test_pgxThreadsType(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	ci := pgtype.NewConnInfo()
+	created := time.Date(2018, 9, 4, 12, 0, 0, 0, time.UTC)
+
+	post := pgtype.NewCompositeBinaryBuilder(ci, nil)
+	post.AppendValue(pgtype.VarcharOID, "t1")
+	post.AppendValue(pgtype.VarcharOID, "p1")
+	post.AppendValue(pgtype.TextOID, "post")
+	post.AppendValue(pgtype.TextOID, nil)
+	post.AppendValue(pgtype.TimestampOID, created)
+	postBytes, err := post.Finish()
+	require.NoError(err)
+
+	thread := pgtype.NewCompositeBinaryBuilder(ci, nil)
+	thread.AppendValue(pgtype.VarcharOID, "f1")
+	thread.AppendValue(pgtype.VarcharOID, "t1")
+	thread.AppendValue(pgtype.TextOID, "thread")
+	thread.AppendValue(pgtype.TextOID, "lorem")
+	thread.AppendValue(pgtype.TimestampOID, created)
+	thread.AppendEncoder(0, rawBinaryType(testCompositeArray(ci, postBytes, nil, postBytes)))
+	threadBytes, err := thread.Finish()
+	require.NoError(err)
+
+	threads := pgxThreadsType{}
+	require.NoError(threads.DecodeBinary(ci, testCompositeArray(ci, threadBytes)))
+	require.Len(threads, 1)
+	assert.Equal("f1", threads[0].ForumID)
+	assert.Equal("t1", threads[0].ThreadID)
+	assert.Equal("lorem", threads[0].Lorem)
+	assert.True(created.Equal(threads[0].Created.Time))
+	require.Len(threads[0].Posts, 2)
+	assert.Equal("p1", threads[0].Posts[1].PostID)
+	assert.Equal("", threads[0].Posts[1].Lorem)
+	assert.Equal(time.UTC, threads[0].Posts[1].Created.Location())
+
+	// forum without threads
+	require.NoError(threads.DecodeBinary(ci, nil))
+	assert.Empty(threads)
+
+	assert.Error(threads.DecodeBinary(ci, testCompositeArray(ci, postBytes)))
+}
+
+// fakePGXConnType is a pgx stdlib driver connection without connection
+type fakePGXConnType struct{}
+
+func (t fakePGXConnType) Prepare(query string) (driver.Stmt, error) {
+	return nil, nil
+}
+
+func (t fakePGXConnType) Close() error {
+	return nil
+}
+
+func (t fakePGXConnType) Begin() (driver.Tx, error) {
+	return nil, nil
+}
+
+func (t fakePGXConnType) Conn() *pgx.Conn {
+	return nil
+}
+
+// fakePGXWrapperType wrap a driver connection and record native queries
+type fakePGXWrapperType struct {
+	driver.Conn
+	inner   driver.Conn
+	queries int
+	rows    [][][]byte
+	fetches int
+}
+
+func (t *fakePGXWrapperType) Unwrap() driver.Conn {
+	return t.inner
+}
+
+func (t *fakePGXWrapperType) RecordPGXQuery(start time.Time) {
+	t.queries++
+}
+
+func (t *fakePGXWrapperType) RecordPGXFetch(start time.Time, values [][]byte) {
+	t.fetches++
+	if values != nil {
+		t.rows = append(t.rows, values)
+	}
+}
+
+// fakePGXRowsType return rows of raw values
+type fakePGXRowsType struct {
+	pgx.Rows
+	rows [][][]byte
+	next int
+}
+
+func (t *fakePGXRowsType) Next() bool {
+	t.next++
+	return t.next <= len(t.rows)
+}
+
+func (t *fakePGXRowsType) RawValues() [][]byte {
+	return t.rows[t.next-1]
+}
+
+func Test_nativePGXConn(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	_, recorder, err := nativePGXConn(fakePGXConnType{})
+	require.NoError(err)
+	assert.Nil(recorder)
+
+	wrapper := &fakePGXWrapperType{inner: fakePGXConnType{}}
+	_, recorder, err = nativePGXConn(wrapper)
+	require.NoError(err)
+	assert.Equal(wrapper, recorder)
+
+	_, _, err = nativePGXConn(struct{ driver.Conn }{})
+	assert.Error(err)
+
+	rows := &recordedPGXRowsType{
+		Rows:     &fakePGXRowsType{rows: [][][]byte{{[]byte("f1"), nil}, {[]byte("f2"), []byte("x")}}},
+		recorder: wrapper,
+	}
+	for rows.Next() {
+	}
+	assert.Equal(3, wrapper.fetches)
+	require.Len(wrapper.rows, 2)
+	assert.Equal([]byte("x"), wrapper.rows[1][1])
+}
@@ -0,0 +1,130 @@
+package forumdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Phases is time spent in each phase of loading data
+type Phases struct {
+	// FirstByte is the time from sending the first statement to its first row
+	FirstByte time.Duration `json:"firstByteNs"`
+	// LastRow is the time spent executing statements and fetching rows
+	LastRow time.Duration `json:"lastRowNs"`
+	// Decode is the time decoding JSON or binary composite values built by the database
+	Decode time.Duration `json:"decodeNs"`
+	// Assemble is the time scanning rows and building the tree in Go
+	Assemble time.Duration `json:"assembleNs"`
+	// firstDone is true after the first statement reached its first row
+	firstDone bool
+	// skipped is time inside scan callbacks accounted to other phases or to OnForum
+	skipped time.Duration
+}
+
+func (t Phases) String() string {
+	return fmt.Sprintf("first-byte=%v last-row=%v decode=%v assemble=%v", t.FirstByte, t.LastRow, t.Decode, t.Assemble)
+}
+
+// Add return the sum of phase times, for accumulating iterations
+func (t Phases) Add(other Phases) Phases {
+	return Phases{
+		FirstByte: t.FirstByte + other.FirstByte,
+		LastRow:   t.LastRow + other.LastRow,
+		Decode:    t.Decode + other.Decode,
+		Assemble:  t.Assemble + other.Assemble,
+	}
+}
+
+// Div return phase times divided by n
+func (t Phases) Div(n int) Phases {
+	if n < 1 {
+		return Phases{}
+	}
+	return Phases{
+		FirstByte: t.FirstByte / time.Duration(n),
+		LastRow:   t.LastRow / time.Duration(n),
+		Decode:    t.Decode / time.Duration(n),
+		Assemble:  t.Assemble / time.Duration(n),
+	}
+}
+
+// addDecode add time since start to decode phase, no-op on nil
+func (t *Phases) addDecode(start time.Time) {
+	if t != nil {
+		elapsed := time.Since(start)
+		t.Decode += elapsed
+		t.skipped += elapsed
+	}
+}
+
+// addSkipped exclude time since start from all phases, e.g. time spent in OnForum, no-op on nil
+func (t *Phases) addSkipped(start time.Time) {
+	if t != nil {
+		t.skipped += time.Since(start)
+	}
+}
+
+// addAssemble add time since start to assemble phase except time skipped after skippedStart, no-op on nil
+func (t *Phases) addAssemble(start time.Time, skippedStart time.Duration) {
+	if t != nil {
+		t.Assemble += time.Since(start) - (t.skipped - skippedStart)
+	}
+}
+
+// skippedTime return total skipped time, 0 on nil
+func (t *Phases) skippedTime() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return t.skipped
+}
+
+func (t *Phases) addFetch(start time.Time, firstRow bool) {
+	if t == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	t.LastRow += elapsed
+	if firstRow && !t.firstDone {
+		t.FirstByte = t.LastRow
+		t.firstDone = true
+	}
+}
+
+// queryRows run query and call scan for every row,
+// statement and fetch time is added to last row phase, scan time to assemble phase
+func queryRows(
+	ctx context.Context,
+	tx sqlx.QueryerContext,
+	phases *Phases,
+	scan func(rows *sqlx.Rows) error,
+	query string,
+	args ...interface{},
+) (err error) {
+	start := time.Now()
+	rows, err := tx.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	first := true
+	for {
+		hasNext := rows.Next()
+		phases.addFetch(start, first)
+		first = false
+		if !hasNext {
+			break
+		}
+		scanStart, skipped := time.Now(), phases.skippedTime()
+		if err = scan(rows); err != nil {
+			return
+		}
+		phases.addAssemble(scanStart, skipped)
+		start = time.Now()
+	}
+	return rows.Err()
+}
@@ -0,0 +1,341 @@
+package forumdb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SearchStrategies are full-text search strategies of all dialects,
+// loading forums in forumID order with threads matching Request.Term or having matching posts,
+// each with its matching posts, threads and posts in ID order,
+// create SearchIndexes first, MySQL can't search without its FULLTEXT indexes
+var SearchStrategies = []Strategy{
+	{
+		Dialect: MySQL,
+		Name:    "app-query",
+		Queries: []string{selectMySQLSearchForumsQuery, selectMySQLSearchThreadsQuery, selectMySQLSearchPostsQuery},
+		Select: withSearch(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			term := request.Term
+			return selectDataAppQueryArgs(ctx, tx, request,
+				selectMySQLSearchForumsQuery, []interface{}{term, term, request.Limit.Forums},
+				selectMySQLSearchThreadsQuery, func(forumID string, limit int) []interface{} {
+					return []interface{}{forumID, term, term, limit}
+				},
+				selectMySQLSearchPostsQuery, func(threadID string, limit int) []interface{} {
+					return []interface{}{threadID, term, limit}
+				},
+			)
+		}),
+	},
+	{
+		Dialect: MySQL,
+		Name:    "sub-query",
+		Queries: []string{selectMySQLSearchSubQuery},
+		Select: withSearch(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			limit, term := request.Limit, request.Term
+			return selectData(ctx, tx, request, selectMySQLSearchSubQuery, term, term, term, limit.Posts, limit.Threads, limit.Forums)
+		}),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "app-query",
+		Queries: []string{selectPGSQLSearchForumsQuery, selectPGSQLSearchThreadsQuery, selectPGSQLSearchPostsQuery},
+		Select: withSearch(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			term := request.Term
+			args := func(parentID string, limit int) []interface{} {
+				return []interface{}{parentID, limit, term}
+			}
+			return selectDataAppQueryArgs(ctx, tx, request,
+				selectPGSQLSearchForumsQuery, []interface{}{term, request.Limit.Forums},
+				selectPGSQLSearchThreadsQuery, args,
+				selectPGSQLSearchPostsQuery, args,
+			)
+		}),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "sub-query",
+		Queries: []string{selectPGSQLSearchSubQuery},
+		Select: withSearch(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			limit := request.Limit
+			return selectData(ctx, tx, request, selectPGSQLSearchSubQuery, limit.Forums, limit.Threads, limit.Posts, request.Term)
+		}),
+	},
+}
+
+// DialectSearchStrategies return all search strategies of dialect
+func DialectSearchStrategies(dialect Dialect) []Strategy {
+	return dialectStrategies(SearchStrategies, dialect)
+}
+
+// SearchIndexes are full-text indexes of thread and post name and lorem the search strategies need
+var SearchIndexes = []Index{
+	{Name: "threads_name_lorem_fts", Table: "threads", Columns: []string{"name", "lorem"}, Dialect: MySQL, Type: IndexFulltext},
+	{Name: "posts_name_lorem_fts", Table: "posts", Columns: []string{"name", "lorem"}, Dialect: MySQL, Type: IndexFulltext},
+	{Name: "threads_name_lorem_fts", Table: "threads", Columns: []string{pgsqlSearchDocument}, Dialect: PGSQL, Type: IndexGIN},
+	{Name: "posts_name_lorem_fts", Table: "posts", Columns: []string{pgsqlSearchDocument}, Dialect: PGSQL, Type: IndexGIN},
+}
+
+// pgsqlSearchDocument is the tsvector of name and lorem, queries must use the same expression to use the GIN index
+const pgsqlSearchDocument = "to_tsvector('english', name || ' ' || lorem)"
+
+// withSearch return select failing on requests with a page or without a term
+func withSearch(selectFn func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error)) func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+	return withoutPage(func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+		if request.Term == "" {
+			return nil, errors.New("search term is empty")
+		}
+		return selectFn(ctx, tx, request)
+	})
+}
+
+const selectMySQLSearchForumsQuery = `
+SELECT f.forumID AS "forumID", JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM forums f
+WHERE f.forumID IN (
+	SELECT t.forumID
+	FROM threads t
+	WHERE MATCH(t.name, t.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+	UNION
+	SELECT t.forumID
+	FROM threads t
+	INNER JOIN posts p ON p.threadID = t.threadID
+	WHERE MATCH(p.name, p.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+)
+ORDER BY f.forumID
+LIMIT ?
+;`
+
+const selectMySQLSearchThreadsQuery = `
+SELECT t.forumID AS "forumID",
+	t.threadID AS "threadID",
+	t.name,
+	t.lorem,
+	t.created
+FROM threads t
+WHERE t.forumID = ? AND (
+	MATCH(t.name, t.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+	OR t.threadID IN (
+		SELECT p.threadID
+		FROM posts p
+		WHERE MATCH(p.name, p.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+	)
+)
+ORDER BY t.threadID
+LIMIT ?
+;`
+
+const selectMySQLSearchPostsQuery = `
+SELECT p.threadID AS "threadID",
+	p.postID AS "postID",
+	p.name,
+	p.lorem,
+	p.created
+FROM posts p
+WHERE p.threadID = ? AND MATCH(p.name, p.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+ORDER BY p.postID
+LIMIT ?
+;`
+
+const selectPGSQLSearchForumsQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM forums f
+WHERE f.forumID IN (
+	SELECT t.forumID
+	FROM threads t
+	WHERE to_tsvector('english', t.name || ' ' || t.lorem) @@ plainto_tsquery('english', $1)
+	UNION
+	SELECT t.forumID
+	FROM threads t
+	INNER JOIN posts p ON p.threadID = t.threadID
+	WHERE to_tsvector('english', p.name || ' ' || p.lorem) @@ plainto_tsquery('english', $1)
+)
+ORDER BY f.forumID
+LIMIT $2
+;`
+
+const selectPGSQLSearchThreadsQuery = `
+SELECT t.forumID AS "forumID",
+	t.threadID AS "threadID",
+	t.name,
+	t.lorem,
+	t.created
+FROM threads t
+WHERE t.forumID = $1 AND (
+	to_tsvector('english', t.name || ' ' || t.lorem) @@ plainto_tsquery('english', $3)
+	OR t.threadID IN (
+		SELECT p.threadID
+		FROM posts p
+		WHERE to_tsvector('english', p.name || ' ' || p.lorem) @@ plainto_tsquery('english', $3)
+	)
+)
+ORDER BY t.threadID
+LIMIT $2
+;`
+
+const selectPGSQLSearchPostsQuery = `
+SELECT p.threadID AS "threadID",
+	p.postID AS "postID",
+	p.name,
+	p.lorem,
+	p.created
+FROM posts p
+WHERE p.threadID = $1 AND to_tsvector('english', p.name || ' ' || p.lorem) @@ plainto_tsquery('english', $3)
+ORDER BY p.postID
+LIMIT $2
+;`
+
+const selectMySQLSearchSubQuery = `
+SELECT f.forumID, JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM forums f
+INNER JOIN (
+	SELECT t.forumID, CAST(CONCAT(
+		'[',
+		GROUP_CONCAT(
+			JSON_OBJECT(
+				'forumID', t.forumID,
+				'threadID', t.threadID,
+				'name', t.name,
+				'lorem', t.lorem,
+				'created', t.created,
+				'posts', p2.posts
+			)
+			ORDER BY t.threadID
+		),
+		']'
+	) AS JSON) AS threads
+	FROM (
+		SELECT @trnum := CASE
+			WHEN @forumID = forumID THEN @trnum + 1
+			ELSE 1
+			END AS trnum,
+			@forumID := forumID AS forumID,
+			threadID,
+			name,
+			lorem,
+			created
+		FROM (
+			SELECT t.forumID, t.threadID, t.name, t.lorem, t.created
+			FROM threads t
+			WHERE MATCH(t.name, t.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+			UNION
+			SELECT t.forumID, t.threadID, t.name, t.lorem, t.created
+			FROM threads t
+			INNER JOIN posts p ON p.threadID = t.threadID
+			WHERE MATCH(p.name, p.lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+		) mt, (SELECT @trnum:=0, @forumID:='') as tt
+		ORDER BY forumID, threadID
+	) t
+	LEFT JOIN (
+		SELECT p.threadID, CAST(CONCAT(
+			'[',
+			GROUP_CONCAT(
+				JSON_OBJECT(
+					'threadID', p.threadID,
+					'postID', p.postID,
+					'name', p.name,
+					'lorem', p.lorem,
+					'created', p.created
+				)
+				ORDER BY p.postID
+			),
+			']'
+		) AS JSON) AS posts
+		FROM (
+			SELECT @prnum := CASE
+				WHEN @threadID = threadID THEN @prnum + 1
+				ELSE 1
+				END AS prnum,
+				@threadID := threadID AS threadID,
+				postID,
+				name,
+				lorem,
+				created
+			FROM (
+				SELECT threadID, postID, name, lorem, created
+				FROM posts
+				WHERE MATCH(name, lorem) AGAINST (? IN NATURAL LANGUAGE MODE)
+			) mp, (SELECT @prnum:=0, @threadID:='') as pt
+			ORDER BY threadID, postID
+		) p
+		WHERE p.prnum <= ?
+		GROUP BY threadID
+	) p2 USING (threadID)
+	WHERE t.trnum <= ?
+	GROUP BY forumID
+) t USING (forumID)
+ORDER BY f.forumID
+LIMIT ?
+;`
+
+const selectPGSQLSearchSubQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM forums f
+INNER JOIN (
+	SELECT t.forumID, JSON_AGG(JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', p.posts
+	) ORDER BY t.threadID) AS threads
+	FROM (
+		SELECT mt.*, ROW_NUMBER() OVER (PARTITION BY forumID ORDER BY threadID) AS rnum
+		FROM (
+			SELECT t.forumID, t.threadID, t.name, t.lorem, t.created
+			FROM threads t
+			WHERE to_tsvector('english', t.name || ' ' || t.lorem) @@ plainto_tsquery('english', $4)
+			UNION
+			SELECT t.forumID, t.threadID, t.name, t.lorem, t.created
+			FROM threads t
+			INNER JOIN posts p ON p.threadID = t.threadID
+			WHERE to_tsvector('english', p.name || ' ' || p.lorem) @@ plainto_tsquery('english', $4)
+		) mt
+	) t
+	LEFT JOIN (
+		SELECT p.threadID, JSON_AGG(JSON_BUILD_OBJECT(
+			'threadID', p.threadID,
+			'postID', p.postID,
+			'name', p.name,
+			'lorem', p.lorem,
+			'created', p.created
+		) ORDER BY p.postID) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID ORDER BY postID) AS rnum
+			FROM posts
+			WHERE to_tsvector('english', name || ' ' || lorem) @@ plainto_tsquery('english', $4)
+		) p
+		WHERE p.rnum <= $3
+		GROUP BY threadID
+	) p USING (threadID)
+	WHERE t.rnum <= $2
+	GROUP BY forumID
+) t USING (forumID)
+ORDER BY f.forumID
+LIMIT $1
+;`
@@ -0,0 +1,51 @@
+package forumdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SearchStrategies(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	for _, dialect := range []Dialect{MySQL, PGSQL} {
+		strategies := DialectSearchStrategies(dialect)
+		require.Len(strategies, 2, dialect)
+		assert.Equal("app-query", strategies[0].Name)
+		assert.Equal("sub-query", strategies[1].Name)
+
+		loader, err := NewSearchLoader(dialect, "sub-query")
+		require.NoError(err)
+		_, err = loader.Search(context.Background(), nil, "", DefaultLimit)
+		assert.Error(err, "empty term")
+		_, err = loader.Strategy.Select(context.Background(), nil, Request{Limit: DefaultLimit, Term: "lorem", Page: Page{Mode: PageOffset, Offset: 10}})
+		assert.Error(err, "page")
+	}
+	_, err := NewSearchLoader(PGSQL, "lateral")
+	assert.Error(err)
+
+	for _, strategy := range DialectSearchStrategies(PGSQL) {
+		for _, query := range strategy.Queries {
+			assert.Contains(query, "to_tsvector('english', ", strategy.Name)
+		}
+	}
+	for _, strategy := range DialectSearchStrategies(MySQL) {
+		for _, query := range strategy.Queries {
+			assert.Contains(query, "MATCH(", strategy.Name)
+		}
+	}
+
+	mysqlIndexes, pgsqlIndexes := DialectIndexes(SearchIndexes, MySQL), DialectIndexes(SearchIndexes, PGSQL)
+	require.Len(mysqlIndexes, 2)
+	require.Len(pgsqlIndexes, 2)
+	assert.Equal("CREATE FULLTEXT INDEX threads_name_lorem_fts ON threads (name, lorem)", mysqlIndexes[0].createQuery())
+	assert.Equal("CREATE INDEX posts_name_lorem_fts ON posts USING GIN (to_tsvector('english', name || ' ' || lorem))", pgsqlIndexes[1].createQuery())
+	assert.Equal("CREATE INDEX threads_forumID_created_idx ON threads (forumID, created)", LatestIndexes[0].createQuery())
+	assert.Len(DialectIndexes(LatestIndexes, MySQL), len(LatestIndexes))
+}
@@ -0,0 +1,375 @@
+package forumdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// selectData load data by a query building the whole nested json,
+// the query must return forumID and data columns,
+// in streaming mode every row is decoded and passed to OnForum before the next row is fetched
+func selectData(ctx context.Context, tx sqlx.QueryerContext, request Request, query string, args ...interface{}) (result []Forum, err error) {
+	decoder := request.decoder()
+	decode := func(raw []byte, forum *Forum) error {
+		if raw == nil {
+			return nil
+		}
+		start := time.Now()
+		err := decoder.Decode(raw, forum)
+		request.Phases.addDecode(start)
+		return err
+	}
+
+	result = []Forum{}
+	raws := [][]byte{}
+	if err = queryRows(ctx, tx, request.Phases, func(rows *sqlx.Rows) (err error) {
+		forum := Forum{}
+		raw := []byte{}
+		if err = rows.Scan(&forum.ForumID, &raw); err != nil {
+			return
+		}
+		if request.OnForum == nil {
+			// decode after all rows are fetched to keep fetch and decode phases apart
+			result = append(result, forum)
+			raws = append(raws, raw)
+			return
+		}
+		if err = decode(raw, &forum); err != nil {
+			return
+		}
+		result, err = request.emit(result, forum)
+		return
+	}, query, args...); err != nil {
+		return
+	}
+
+	for i, raw := range raws {
+		if err = decode(raw, &result[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// selectDataAppQuery load forums by forumsQuery with forumsArgs, then threads of each forum, then posts of each thread,
+// threadsQuery and postsQuery take parent ID and limit
+func selectDataAppQuery(
+	ctx context.Context,
+	tx sqlx.QueryerContext,
+	request Request,
+	forumsQuery string,
+	forumsArgs []interface{},
+	threadsQuery string,
+	postsQuery string,
+) (result []Forum, err error) {
+	return selectDataAppQueryArgs(ctx, tx, request, forumsQuery, forumsArgs, threadsQuery, parentLimitArgs, postsQuery, parentLimitArgs)
+}
+
+// parentLimitArgs return parent ID and limit as args of a child level query
+func parentLimitArgs(parentID string, limit int) []interface{} {
+	return []interface{}{parentID, limit}
+}
+
+// selectDataAppQueryArgs is selectDataAppQuery with args of threadsQuery and postsQuery built by threadsArgs and postsArgs
+func selectDataAppQueryArgs(
+	ctx context.Context,
+	tx sqlx.QueryerContext,
+	request Request,
+	forumsQuery string,
+	forumsArgs []interface{},
+	threadsQuery string,
+	threadsArgs func(forumID string, limit int) []interface{},
+	postsQuery string,
+	postsArgs func(threadID string, limit int) []interface{},
+) (result []Forum, err error) {
+	limit := request.Limit
+	forumsRequest := request
+	forumsRequest.OnForum = nil
+	forums, err := selectData(ctx, tx, forumsRequest, forumsQuery, forumsArgs...)
+	if err != nil {
+		return
+	}
+
+	for fi := range forums {
+		forum := &forums[fi]
+		if err = queryRows(ctx, tx, request.Phases, func(rows *sqlx.Rows) error {
+			thread := Thread{}
+			if err := rows.StructScan(&thread); err != nil {
+				return err
+			}
+			forum.Threads = append(forum.Threads, thread)
+			return nil
+		}, threadsQuery, threadsArgs(forum.ForumID, limit.Threads)...); err != nil {
+			return
+		}
+
+		for ti := range forum.Threads {
+			thread := &forum.Threads[ti]
+			if err = queryRows(ctx, tx, request.Phases, func(rows *sqlx.Rows) error {
+				post := Post{}
+				if err := rows.StructScan(&post); err != nil {
+					return err
+				}
+				thread.Posts = append(thread.Posts, post)
+				return nil
+			}, postsQuery, postsArgs(thread.ThreadID, limit.Posts)...); err != nil {
+				return
+			}
+		}
+
+		if request.OnForum != nil {
+			if _, err = request.emit(nil, *forum); err != nil {
+				return
+			}
+			// release the passed tree, only the forum list is kept
+			forums[fi] = Forum{}
+		}
+	}
+	if request.OnForum != nil {
+		return []Forum{}, nil
+	}
+	return forums, nil
+}
+
+func selectDataMyAppQuery(ctx context.Context, tx sqlx.QueryerContext, request Request) (result []Forum, err error) {
+	forumsQuery, forumsArgs, err := request.pageQuery(MySQL, selectMySQLForumsQuery, request.Limit.Forums)
+	if err != nil {
+		return
+	}
+	return selectDataAppQuery(ctx, tx, request, forumsQuery, forumsArgs, selectMySQLThreadsQuery, selectMySQLPostsQuery)
+}
+
+func selectDataPGAppQuery(ctx context.Context, tx sqlx.QueryerContext, request Request) (result []Forum, err error) {
+	forumsQuery, forumsArgs, err := request.pageQuery(PGSQL, selectPGSQLForumsQuery, request.Limit.Forums)
+	if err != nil {
+		return
+	}
+	return selectDataAppQuery(ctx, tx, request, forumsQuery, forumsArgs, selectPGSQLThreadsQuery, selectPGSQLPostsQuery)
+}
+
+const selectMySQLForumsQuery = `
+SELECT f.forumID AS "forumID", JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM forums f
+LIMIT ?
+;`
+
+const selectMySQLThreadsQuery = `
+SELECT t.forumID AS "forumID",
+	t.threadID AS "threadID",
+	t.name,
+	t.lorem,
+	t.created
+FROM threads t
+WHERE forumID = ?
+LIMIT ?
+;`
+
+const selectMySQLPostsQuery = `
+SELECT p.threadID AS "threadID",
+	p.postID AS "postID",
+	p.name,
+	p.lorem,
+	p.created
+FROM posts p
+WHERE threadID = ?
+LIMIT ?
+;`
+
+const selectPGSQLForumsQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created
+) AS data
+FROM forums f
+LIMIT $1
+;`
+
+const selectPGSQLThreadsQuery = `
+SELECT t.forumID AS "forumID",
+	t.threadID AS "threadID",
+	t.name,
+	t.lorem,
+	t.created
+FROM threads t
+WHERE forumID = $1
+LIMIT $2
+;`
+
+const selectPGSQLPostsQuery = `
+SELECT p.threadID AS "threadID",
+	p.postID AS "postID",
+	p.name,
+	p.lorem,
+	p.created
+FROM posts p
+WHERE threadID = $1
+LIMIT $2
+;`
+
+const selectMySQLDataSubQuery = `
+SELECT f.forumID, JSON_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM forums f
+INNER JOIN (
+	SELECT t.forumID, CAST(CONCAT(
+		'[',
+		GROUP_CONCAT(
+			JSON_OBJECT(
+				'forumID', t.forumID,
+				'threadID', t.threadID,
+				'name', t.name,
+				'lorem', t.lorem,
+				'created', t.created,
+				'posts', p2.posts
+			)
+		),
+		']'
+	) AS JSON) AS threads
+	FROM (
+		SELECT @trnum := CASE
+			WHEN @forumID = forumID THEN @trnum + 1
+			ELSE 1
+			END AS trnum,
+			@forumID := forumID AS forumID,
+			threadID,
+			name,
+			lorem,
+			created
+		FROM threads, (SELECT @trnum:=0, @forumID:='') as tt
+		ORDER BY forumID
+	) t
+	INNER JOIN (
+		SELECT p.threadID, CAST(CONCAT(
+			'[',
+			GROUP_CONCAT(
+				JSON_OBJECT(
+					'threadID', p.threadID,
+					'postID', p.postID,
+					'name', p.name,
+					'lorem', p.lorem,
+					'created', p.created
+				)
+			),
+			']'
+		) AS JSON) AS posts
+		FROM (
+			SELECT @prnum := CASE
+				WHEN @threadID = threadID THEN @prnum + 1
+				ELSE 1
+				END AS prnum,
+				@threadID := threadID AS threadID,
+				postID,
+				name,
+				lorem,
+				created
+			FROM posts, (SELECT @prnum:=0, @threadID:='') as pt
+			ORDER BY threadID
+		) p
+		WHERE p.prnum <= ?
+		GROUP BY threadID
+	) p2 USING (threadID)
+	WHERE t.trnum <= ?
+	GROUP BY forumID
+) t USING (forumID)
+LIMIT ?
+;`
+
+const selectPGSQLDataSubQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', t.threads
+) AS data
+FROM forums f
+INNER JOIN (
+	SELECT t.forumID, JSON_AGG(JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', p.posts
+	)) AS threads
+	FROM (
+		SELECT forumID, threadID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY forumID) AS rnum
+		FROM threads
+	) t
+	INNER JOIN (
+		SELECT p.threadID, JSON_AGG(JSON_BUILD_OBJECT(
+			'threadID', p.threadID,
+			'postID', p.postID,
+			'name', p.name,
+			'lorem', p.lorem,
+			'created', p.created
+		)) AS posts
+		FROM (
+			SELECT threadID, postID, name, lorem, created, ROW_NUMBER() OVER (PARTITION BY threadID) AS rnum
+			FROM posts
+		) p
+		WHERE p.rnum <= $3
+		GROUP BY threadID
+	) p USING (threadID)
+	WHERE t.rnum <= $2
+	GROUP BY forumID
+) t USING (forumID)
+LIMIT $1
+;`
+
+const selectPGSQLDataLateralQuery = `
+SELECT f.forumID AS "forumID", JSON_BUILD_OBJECT(
+	'forumID', f.forumID,
+	'name', f.name,
+	'lorem', f.lorem,
+	'created', f.created,
+	'threads', JSON_AGG(t2.thread)
+) AS data
+FROM forums f
+JOIN LATERAL (
+	SELECT t.forumID, t.threadID, JSON_BUILD_OBJECT(
+		'forumID', t.forumID,
+		'threadID', t.threadID,
+		'name', t.name,
+		'lorem', t.lorem,
+		'created', t.created,
+		'posts', JSON_AGG(p2.post)
+	) AS thread
+	FROM threads t
+	JOIN LATERAL (
+		SELECT p.threadID,
+			p.postID,
+			JSON_BUILD_OBJECT(
+				'threadID', p.threadID,
+				'postID', p.postID,
+				'name', p.name,
+				'lorem', p.lorem,
+				'created', p.created
+			) AS post
+		FROM posts p
+		WHERE p.threadID = t.threadID
+		LIMIT $3
+	) p2
+	ON TRUE
+	WHERE t.forumID = f.forumID
+	GROUP BY t.forumID, t.threadID
+	LIMIT $2
+) t2
+ON TRUE
+GROUP BY f.forumID
+LIMIT $1
+;`
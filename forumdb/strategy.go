@@ -0,0 +1,208 @@
+package forumdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Dialect is the SQL dialect of a database
+type Dialect string
+
+// supported dialects
+const (
+	MySQL Dialect = "mysql"
+	PGSQL Dialect = "pgsql"
+)
+
+// Strategy is one way to load the nested forum data
+type Strategy struct {
+	Dialect Dialect
+	Name    string
+	// Queries are all SQL statements issued by Select
+	Queries []string
+	// Driver is the database/sql driver Select needs, empty means the dialect default
+	Driver string
+	// Select load data with tx, usually a transaction so all statements read the same snapshot
+	Select func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error)
+}
+
+// Request is the input of strategy Select
+type Request struct {
+	Limit Limit
+	// Page select the page of forums, the first forums in no specific order when zero
+	Page Page
+	// ForumID is the forum loaded by point lookup strategies
+	ForumID string
+	// Term is the word searched by search strategies
+	Term string
+	// Decoder decode JSON built by the database, default decoder when not set
+	Decoder Decoder
+	// Phases accumulate time of each phase when not nil
+	Phases *Phases
+	// OnForum receive every forum as soon as it is complete when not nil,
+	// Select then keep and return no forum, time spent in OnForum is excluded from phases
+	OnForum func(forum Forum) error
+}
+
+// emit append forum to result, or pass it to OnForum in streaming mode
+func (t Request) emit(result []Forum, forum Forum) ([]Forum, error) {
+	if t.OnForum == nil {
+		return append(result, forum), nil
+	}
+	start := time.Now()
+	err := t.OnForum(forum)
+	t.Phases.addSkipped(start)
+	return result, err
+}
+
+func (t Request) decoder() Decoder {
+	if t.Decoder.Decode == nil {
+		return DefaultDecoder
+	}
+	return t.Decoder
+}
+
+// Limit is the max returned count of each level
+type Limit struct {
+	Forums  int `json:"forums"`
+	Threads int `json:"threads"`
+	Posts   int `json:"posts"`
+}
+
+func (t Limit) String() string {
+	return fmt.Sprintf("%dx%dx%d", t.Forums, t.Threads, t.Posts)
+}
+
+// DefaultLimit return 10 posts of 10 threads of 10 forums
+var DefaultLimit = Limit{Forums: 10, Threads: 10, Posts: 10}
+
+// Strategies are all strategies of all dialects
+var Strategies = []Strategy{
+	{
+		Dialect: MySQL,
+		Name:    "app-query",
+		Queries: []string{selectMySQLForumsQuery, selectMySQLThreadsQuery, selectMySQLPostsQuery},
+		Select:  selectDataMyAppQuery,
+	},
+	{
+		Dialect: MySQL,
+		Name:    "sub-query",
+		Queries: []string{selectMySQLDataSubQuery},
+		Select: func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+			limit := request.Limit
+			query, args, err := request.pageQuery(MySQL, limitQuery(MySQL, selectMySQLDataSubQuery, limit.Posts, limit.Threads, limit.Forums))
+			if err != nil {
+				return nil, err
+			}
+			return selectData(ctx, tx, request, query, args...)
+		},
+	},
+	{
+		Dialect: MySQL,
+		Name:    "flat-join",
+		Queries: []string{selectMySQLDataFlatJoinQuery},
+		Select:  selectDataFlatJoin(MySQL, selectMySQLDataFlatJoinQuery),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "app-query",
+		Queries: []string{selectPGSQLForumsQuery, selectPGSQLThreadsQuery, selectPGSQLPostsQuery},
+		Select:  selectDataPGAppQuery,
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "sub-query",
+		Queries: []string{selectPGSQLDataSubQuery},
+		Select:  selectPGSQLDataQuery(selectPGSQLDataSubQuery),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "lateral",
+		Queries: []string{selectPGSQLDataLateralQuery},
+		Select:  selectPGSQLDataQuery(selectPGSQLDataLateralQuery),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "flat-join",
+		Queries: []string{selectPGSQLDataFlatJoinQuery},
+		Select:  selectDataFlatJoin(PGSQL, selectPGSQLDataFlatJoinQuery),
+	},
+	{
+		Dialect: PGSQL,
+		Name:    "composite-array",
+		Queries: []string{selectPGSQLDataCompositeArrayQuery},
+		Driver:  DriverPGX,
+		Select:  selectDataCompositeArray,
+	},
+	{
+		// lateral on the pgx pool, the JSON baseline of composite-array on the same driver
+		Dialect: PGSQL,
+		Name:    "lateral-pgx",
+		Queries: []string{selectPGSQLDataLateralQuery},
+		Driver:  DriverPGX,
+		Select:  selectPGSQLDataQuery(selectPGSQLDataLateralQuery),
+	},
+}
+
+// DialectStrategies return all strategies of dialect
+func DialectStrategies(dialect Dialect) []Strategy {
+	return dialectStrategies(Strategies, dialect)
+}
+
+func dialectStrategies(strategies []Strategy, dialect Dialect) (result []Strategy) {
+	for _, strategy := range strategies {
+		if strategy.Dialect == dialect {
+			result = append(result, strategy)
+		}
+	}
+	return
+}
+
+// selectPGSQLDataQuery return Select of query with forum, thread and post limits as $1, $2 and $3
+func selectPGSQLDataQuery(query string) func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+	return func(ctx context.Context, tx sqlx.QueryerContext, request Request) ([]Forum, error) {
+		limit := request.Limit
+		pagedQuery, args, err := request.pageQuery(PGSQL, limitQuery(PGSQL, query, limit.Forums, limit.Threads, limit.Posts))
+		if err != nil {
+			return nil, err
+		}
+		return selectData(ctx, tx, request, pagedQuery, args...)
+	}
+}
+
+type limitQueryKeyType struct {
+	Query  string
+	Limits string
+}
+
+// limitQueries cache query text of every limits, so iterations don't rebuild query text
+var limitQueries = sync.Map{}
+
+// limitQuery return query with its limit placeholders replaced by limits as SQL text,
+// limits are in order of ? for MySQL and of $1, $2, ... for PostgreSQL,
+// a statement without bound arguments is sent in one round trip, drivers prepare it first otherwise
+func limitQuery(dialect Dialect, query string, limits ...int) string {
+	key := limitQueryKeyType{Query: query, Limits: fmt.Sprint(limits)}
+	if limited, ok := limitQueries.Load(key); ok {
+		return limited.(string)
+	}
+	limited := query
+	if dialect == MySQL {
+		for _, limit := range limits {
+			limited = strings.Replace(limited, "?", strconv.Itoa(limit), 1)
+		}
+	} else {
+		// replace from the last placeholder, so $1 doesn't match the prefix of $10
+		for i := len(limits); i > 0; i-- {
+			limited = strings.Replace(limited, "$"+strconv.Itoa(i), strconv.Itoa(limits[i-1]), -1)
+		}
+	}
+	limitQueries.Store(key, limited)
+	return limited
+}
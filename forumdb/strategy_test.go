@@ -0,0 +1,39 @@
+package forumdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_limitQuery(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	limit := Limit{Forums: 10, Threads: 5, Posts: 3}
+
+	query := limitQuery(MySQL, selectMySQLDataSubQuery, limit.Posts, limit.Threads, limit.Forums)
+	assert.NotContains(query, "?")
+	assert.Contains(query, "WHERE p.prnum <= 3")
+	assert.Contains(query, "WHERE t.trnum <= 5")
+	assert.Contains(query, "\nLIMIT 10\n")
+
+	for _, query := range []string{selectPGSQLDataSubQuery, selectPGSQLDataLateralQuery} {
+		query = limitQuery(PGSQL, query, limit.Forums, limit.Threads, limit.Posts)
+		assert.NotContains(query, "$")
+		assert.Contains(query, "\nLIMIT 10\n")
+	}
+	assert.Contains(limitQuery(PGSQL, selectPGSQLDataSubQuery, limit.Forums, limit.Threads, limit.Posts), "WHERE p.rnum <= 3")
+	assert.Contains(limitQuery(PGSQL, selectPGSQLDataLateralQuery, limit.Forums, limit.Threads, limit.Posts), "\tLIMIT 5\n")
+
+	query = limitQuery(MySQL, selectMySQLDataFlatJoinQuery, limit.Forums, limit.Threads, limit.Posts)
+	assert.Contains(query, "\tLIMIT 10\n")
+	assert.Contains(query, "t.rnum <= 5")
+	assert.Contains(query, "p.rnum <= 3")
+
+	// cached text is per limit
+	assert.Contains(limitQuery(MySQL, selectMySQLDataSubQuery, 1, 1, 1), "\nLIMIT 1\n")
+}
@@ -0,0 +1,75 @@
+package forumdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Timestamp is a time decoded from any dialect format, always in UTC,
+// values without offset are UTC because connections run with UTC session time zone
+type Timestamp struct {
+	time.Time
+}
+
+// timestampLayouts are accepted formats, fraction of second is optional
+var timestampLayouts = []string{
+	// MySQL JSON_OBJECT and DATETIME/TIMESTAMP text
+	"2006-01-02 15:04:05.999999999",
+	// PostgreSQL JSON of timestamp
+	"2006-01-02T15:04:05.999999999",
+	// PostgreSQL JSON of timestamptz
+	time.RFC3339Nano,
+	// PostgreSQL text of timestamptz
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+}
+
+// parseTimestamp parse value of any accepted layout into UTC
+func parseTimestamp(value string) (result Timestamp, err error) {
+	for _, layout := range timestampLayouts {
+		t, errParse := time.ParseInLocation(layout, value, time.UTC)
+		if errParse == nil {
+			return Timestamp{Time: t.UTC()}, nil
+		}
+	}
+	return result, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// Scan implement sql.Scanner for driver time or text values
+func (t *Timestamp) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case nil:
+		*t = Timestamp{}
+	case time.Time:
+		*t = Timestamp{Time: v.UTC()}
+	case []byte:
+		*t, err = parseTimestamp(string(v))
+	case string:
+		*t, err = parseTimestamp(v)
+	default:
+		err = fmt.Errorf("unsupported timestamp type %T", value)
+	}
+	return
+}
+
+// Value implement driver.Valuer
+func (t Timestamp) Value() (driver.Value, error) {
+	return t.Time, nil
+}
+
+// UnmarshalJSON decode JSON string of any accepted layout, null is zero time
+func (t *Timestamp) UnmarshalJSON(data []byte) (err error) {
+	if bytes.Equal(data, []byte("null")) {
+		*t = Timestamp{}
+		return
+	}
+	value := ""
+	if err = json.Unmarshal(data, &value); err != nil {
+		return
+	}
+	*t, err = parseTimestamp(value)
+	return
+}
@@ -0,0 +1,55 @@
+package forumdb
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseTimestamp(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	expected := time.Date(2018, 9, 4, 12, 0, 0, 0, time.UTC)
+	for _, value := range []string{
+		"2018-09-04 12:00:00",
+		"2018-09-04 12:00:00.000000",
+		"2018-09-04T12:00:00",
+		"2018-09-04T12:00:00Z",
+		"2018-09-04T20:00:00+08:00",
+		"2018-09-04 20:00:00+08",
+	} {
+		result, err := parseTimestamp(value)
+		require.NoError(err, value)
+		assert.True(expected.Equal(result.Time), value)
+		assert.Equal(time.UTC, result.Location(), value)
+	}
+
+	result, err := parseTimestamp("2018-09-04 12:00:00.123456")
+	require.NoError(err)
+	assert.Equal(123456000, result.Nanosecond())
+
+	_, err = parseTimestamp("04/09/2018")
+	assert.Error(err)
+
+	scanned := Timestamp{}
+	require.NoError(scanned.Scan(time.Date(2018, 9, 4, 20, 0, 0, 0, time.FixedZone("", 8*3600))))
+	assert.True(expected.Equal(scanned.Time))
+	assert.Equal(time.UTC, scanned.Location())
+	require.NoError(scanned.Scan([]byte("2018-09-04 12:00:00")))
+	assert.True(expected.Equal(scanned.Time))
+
+	decoded := struct {
+		Created Timestamp
+		Deleted Timestamp
+	}{}
+	require.NoError(json.Unmarshal([]byte(`{"created": "2018-09-04T12:00:00", "deleted": null}`), &decoded))
+	assert.True(expected.Equal(decoded.Created.Time))
+	assert.True(decoded.Deleted.IsZero())
+
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// driverStatsType count driver operations of a target, fields are updated atomically
+type driverStatsType struct {
+	// Queries is the count of executed statements
+	Queries int64
+	// Rows is the count of rows returned by the driver
+	Rows int64
+	// Bytes is the count of bytes received from the server on the wire, including connection handshakes,
+	// only counted for drivers dialing by wireConnector
+	Bytes int64
+	// DriverTime is the time spent inside driver calls
+	DriverTime time.Duration
+}
+
+// Load return a copy of current counters
+func (t *driverStatsType) Load() driverStatsType {
+	return driverStatsType{
+		Queries:    atomic.LoadInt64(&t.Queries),
+		Rows:       atomic.LoadInt64(&t.Rows),
+		Bytes:      atomic.LoadInt64(&t.Bytes),
+		DriverTime: time.Duration(atomic.LoadInt64((*int64)(&t.DriverTime))),
+	}
+}
+
+// Reset set all counters to zero
+func (t *driverStatsType) Reset() {
+	atomic.StoreInt64(&t.Queries, 0)
+	atomic.StoreInt64(&t.Rows, 0)
+	atomic.StoreInt64(&t.Bytes, 0)
+	atomic.StoreInt64((*int64)(&t.DriverTime), 0)
+}
+
+func (t *driverStatsType) addQuery(start time.Time) {
+	atomic.AddInt64(&t.Queries, 1)
+	t.addTime(start)
+}
+
+func (t *driverStatsType) addTime(start time.Time) {
+	atomic.AddInt64((*int64)(&t.DriverTime), int64(time.Since(start)))
+}
+
+func (t *driverStatsType) addRow() {
+	atomic.AddInt64(&t.Rows, 1)
+}
+
+// dial connect to address of network, bytes received by the connection are counted
+func (t *driverStatsType) dial(ctx context.Context, network string, address string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &wireConnType{Conn: conn, stats: t}, nil
+}
+
+// wireConnType count bytes read from Conn into stats
+type wireConnType struct {
+	net.Conn
+	stats *driverStatsType
+}
+
+func (t *wireConnType) Read(b []byte) (n int, err error) {
+	n, err = t.Conn.Read(b)
+	atomic.AddInt64(&t.stats.Bytes, int64(n))
+	return
+}
+
+// pqDialerType dial lib/pq connections by stats
+type pqDialerType struct {
+	stats *driverStatsType
+}
+
+func (t pqDialerType) Dial(network string, address string) (net.Conn, error) {
+	return t.stats.dial(context.Background(), network, address)
+}
+
+func (t pqDialerType) DialTimeout(network string, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return t.stats.dial(ctx, network, address)
+}
+
+func (t pqDialerType) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return t.stats.dial(ctx, network, address)
+}
+
+// mysqlWireNets count networks registered to the mysql driver, every connector dial by its own network
+var mysqlWireNets int64
+
+// wireConnector return connector of driverName dialing by stats, so bytes received are counted,
+// nil for drivers without dial hooks
+func wireConnector(driverName string, connURL string, stats *driverStatsType) (driver.Connector, error) {
+	switch driverName {
+	case "mysql":
+		config, err := mysql.ParseDSN(connURL)
+		if err != nil {
+			return nil, err
+		}
+		network := config.Net
+		config.Net = fmt.Sprintf("wire-%s-%d", network, atomic.AddInt64(&mysqlWireNets, 1))
+		mysql.RegisterDialContext(config.Net, func(ctx context.Context, address string) (net.Conn, error) {
+			return stats.dial(ctx, network, address)
+		})
+		return mysql.NewConnector(config)
+	case "postgres":
+		connector, err := pq.NewConnector(connURL)
+		if err != nil {
+			return nil, err
+		}
+		connector.Dialer(pqDialerType{stats: stats})
+		return connector, nil
+	case forumdb.DriverPGX:
+		config, err := pgx.ParseConfig(connURL)
+		if err != nil {
+			return nil, err
+		}
+		config.DialFunc = stats.dial
+		return stdlib.GetConnector(*config), nil
+	}
+	return nil, nil
+}
+
+// openInstrumentedDB open connURL with the registered driver wrapped to count into stats,
+// the returned db keeps driverName for sqlx bind vars
+func openInstrumentedDB(driverName string, connURL string, stats *driverStatsType) (db *sqlx.DB, err error) {
+	base, err := sql.Open(driverName, connURL)
+	if err != nil {
+		return
+	}
+	baseDriver := base.Driver()
+	if err = base.Close(); err != nil {
+		return
+	}
+
+	connector := &instrumentedConnectorType{
+		dsn:    connURL,
+		driver: baseDriver,
+		stats:  stats,
+	}
+	if connector.connector, err = wireConnector(driverName, connURL, stats); err != nil {
+		return
+	}
+	if driverContext, ok := baseDriver.(driver.DriverContext); ok && connector.connector == nil {
+		if connector.connector, err = driverContext.OpenConnector(connURL); err != nil {
+			return
+		}
+	}
+	return sqlx.NewDb(sql.OpenDB(connector), driverName), nil
+}
+
+type instrumentedConnectorType struct {
+	dsn       string
+	driver    driver.Driver
+	connector driver.Connector
+	stats     *driverStatsType
+}
+
+func (t *instrumentedConnectorType) Connect(ctx context.Context) (conn driver.Conn, err error) {
+	if t.connector != nil {
+		conn, err = t.connector.Connect(ctx)
+	} else {
+		conn, err = t.driver.Open(t.dsn)
+	}
+	if err != nil {
+		return
+	}
+	return &instrumentedConnType{Conn: conn, stats: t.stats}, nil
+}
+
+func (t *instrumentedConnectorType) Driver() driver.Driver {
+	return t.driver
+}
+
+// instrumentedConnType pass every call to Conn, optional interfaces not implemented by Conn fall back
+// to what database/sql does without them
+type instrumentedConnType struct {
+	driver.Conn
+	stats *driverStatsType
+}
+
+// Unwrap return the wrapped driver connection, e.g. for native pgx queries
+func (t *instrumentedConnType) Unwrap() driver.Conn {
+	return t.Conn
+}
+
+// RecordPGXQuery implement forumdb.PGXRecorder to count native pgx queries
+func (t *instrumentedConnType) RecordPGXQuery(start time.Time) {
+	t.stats.addQuery(start)
+}
+
+// RecordPGXFetch implement forumdb.PGXRecorder
+func (t *instrumentedConnType) RecordPGXFetch(start time.Time, values [][]byte) {
+	t.stats.addTime(start)
+	if values != nil {
+		t.stats.addRow()
+	}
+}
+
+func (t *instrumentedConnType) Prepare(query string) (driver.Stmt, error) {
+	return t.PrepareContext(context.Background(), query)
+}
+
+func (t *instrumentedConnType) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	start := time.Now()
+	if prepare, ok := t.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = prepare.PrepareContext(ctx, query)
+	} else {
+		stmt, err = t.Conn.Prepare(query)
+	}
+	t.stats.addTime(start)
+	if err != nil {
+		return
+	}
+	return &instrumentedStmtType{Stmt: stmt, stats: t.stats}, nil
+}
+
+func (t *instrumentedConnType) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if begin, ok := t.Conn.(driver.ConnBeginTx); ok {
+		return begin.BeginTx(ctx, opts)
+	}
+	// driver without BeginTx, database/sql also refuse options it can not pass
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("driver does not support read-only transactions")
+	}
+	return t.Conn.Begin()
+}
+
+func (t *instrumentedConnType) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	queryer, ok := t.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err = queryer.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return
+	}
+	t.stats.addQuery(start)
+	if err != nil {
+		return
+	}
+	return &instrumentedRowsType{Rows: rows, stats: t.stats}, nil
+}
+
+func (t *instrumentedConnType) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	execer, ok := t.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err = execer.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return
+	}
+	t.stats.addQuery(start)
+	return
+}
+
+func (t *instrumentedConnType) Ping(ctx context.Context) error {
+	if pinger, ok := t.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (t *instrumentedConnType) ResetSession(ctx context.Context) error {
+	if resetter, ok := t.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (t *instrumentedConnType) IsValid() bool {
+	if validator, ok := t.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (t *instrumentedConnType) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := t.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type instrumentedStmtType struct {
+	driver.Stmt
+	stats *driverStatsType
+}
+
+func (t *instrumentedStmtType) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	start := time.Now()
+	if queryer, ok := t.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = t.Stmt.Query(namedValues(args)) // driver without StmtQueryContext
+	}
+	t.stats.addQuery(start)
+	if err != nil {
+		return
+	}
+	return &instrumentedRowsType{Rows: rows, stats: t.stats}, nil
+}
+
+func (t *instrumentedStmtType) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	start := time.Now()
+	if execer, ok := t.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = t.Stmt.Exec(namedValues(args)) // driver without StmtExecContext
+	}
+	t.stats.addQuery(start)
+	return
+}
+
+func (t *instrumentedStmtType) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := t.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	return values
+}
+
+type instrumentedRowsType struct {
+	driver.Rows
+	stats *driverStatsType
+}
+
+func (t *instrumentedRowsType) Next(dest []driver.Value) (err error) {
+	start := time.Now()
+	err = t.Rows.Next(dest)
+	t.stats.addTime(start)
+	if err == nil {
+		t.stats.addRow()
+	}
+	return
+}
+
+func (t *instrumentedRowsType) Close() (err error) {
+	start := time.Now()
+	err = t.Rows.Close()
+	t.stats.addTime(start)
+	return
+}
@@ -3,11 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/url"
-	"os"
-	"regexp"
 
 	"github.com/jmoiron/sqlx"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
 )
 
 type loggerType interface {
@@ -22,91 +21,35 @@ func (t consoleLoggerType) Logf(format string, args ...interface{}) {
 
 var consoleLogger loggerType = consoleLoggerType{}
 
-func showDataCounts(logger loggerType, data []selectDataType) {
+// dataShapeType is the row count of each table
+type dataShapeType struct {
+	ForumsCount  int64 `json:"forums"`
+	ThreadsCount int64 `json:"threads"`
+	PostsCount   int64 `json:"posts"`
+}
+
+func showDataCounts(logger loggerType, data []forumdb.Forum) {
 	forumCount := len(data)
 	threadCount := 0
 	postCount := 0
 	for _, forum := range data {
-		threadCount += len(forum.Data.Threads)
-		for _, thread := range forum.Data.Threads {
+		threadCount += len(forum.Threads)
+		for _, thread := range forum.Threads {
 			postCount += len(thread.Posts)
 		}
 	}
 	logger.Logf("forum: %d , thread: %d , post: %d\n", forumCount, threadCount, postCount)
 }
 
-func showMySQLDataCount(ctx context.Context, tx *sqlx.Tx, logger loggerType) {
-	connURL, err := url.Parse(os.Getenv("MYSQL_URL"))
-	if err != nil {
-		panic(err)
-	}
-	reDBName := regexp.MustCompile(`/(\w+)`)
-	matches := reDBName.FindAllStringSubmatch(connURL.Opaque, -1)
-	dbName := matches[len(matches)-1][1]
-
-	result := struct {
-		ForumsCount  int64
-		ThreadsCount int64
-		PostsCount   int64
-	}{}
-
-	if err := tx.GetContext(ctx, &result.ForumsCount, `
-SELECT TABLE_ROWS
-FROM INFORMATION_SCHEMA.TABLES
-WHERE TABLE_SCHEMA = ?
-AND TABLE_NAME = 'forums'
-	;`, dbName); err != nil {
-		panic(err)
+// selectDataShape return exact row counts of each table by COUNT(*),
+// results of different runs are matched by shape so estimates can not be used
+func selectDataShape(ctx context.Context, tx sqlx.QueryerContext) (shape dataShapeType, err error) {
+	if err = sqlx.GetContext(ctx, tx, &shape.ForumsCount, `SELECT COUNT(*) FROM forums;`); err != nil {
+		return
 	}
-
-	if err := tx.GetContext(ctx, &result.ThreadsCount, `
-SELECT TABLE_ROWS
-FROM INFORMATION_SCHEMA.TABLES
-WHERE TABLE_SCHEMA = ?
-AND TABLE_NAME = 'threads'
-	;`, dbName); err != nil {
-		panic(err)
+	if err = sqlx.GetContext(ctx, tx, &shape.ThreadsCount, `SELECT COUNT(*) FROM threads;`); err != nil {
+		return
 	}
-
-	if err := tx.GetContext(ctx, &result.PostsCount, `
-SELECT TABLE_ROWS
-FROM INFORMATION_SCHEMA.TABLES
-WHERE TABLE_SCHEMA = ?
-AND TABLE_NAME = 'posts'
-	;`, dbName); err != nil {
-		panic(err)
-	}
-
-	logger.Logf("Total %+v\n", result)
-}
-
-func showPGSQLDataCount(ctx context.Context, tx *sqlx.Tx, logger loggerType) {
-	result := struct {
-		ForumsCount  int64
-		ThreadsCount int64
-		PostsCount   int64
-	}{}
-
-	if err := tx.GetContext(ctx, &result.ForumsCount, `
-SELECT COUNT(forumID)
-FROM forums
-	;`); err != nil {
-		panic(err)
-	}
-
-	if err := tx.GetContext(ctx, &result.ThreadsCount, `
-SELECT COUNT(threadID)
-FROM threads
-	;`); err != nil {
-		panic(err)
-	}
-
-	if err := tx.GetContext(ctx, &result.PostsCount, `
-SELECT COUNT(postID)
-FROM posts
-	;`); err != nil {
-		panic(err)
-	}
-
-	logger.Logf("Total %+v\n", result)
+	err = sqlx.GetContext(ctx, tx, &shape.PostsCount, `SELECT COUNT(*) FROM posts;`)
+	return
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lookupType is how a point lookup run case picks the forum of every iteration,
+// empty means the run case is not a point lookup
+type lookupType string
+
+// supported lookup distributions
+const (
+	lookupNone lookupType = ""
+	// lookupFixed pick the same forum every iteration
+	lookupFixed lookupType = "fixed"
+	// lookupRandom pick a uniformly random forum
+	lookupRandom lookupType = "random"
+	// lookupZipf pick forums by Zipf distribution, a few hot forums take most lookups
+	lookupZipf lookupType = "zipf"
+)
+
+var lookupTypes = []lookupType{lookupFixed, lookupRandom, lookupZipf}
+
+// zipfS is the Zipf exponent, the hottest forum takes about 18% of lookups of 1000 forums
+const zipfS = 1.1
+
+// parseLookups parse comma separated lookup distributions
+func parseLookups(names string) (result []lookupType, err error) {
+	for _, name := range splitNames(names) {
+		found := false
+		for _, lookup := range lookupTypes {
+			if string(lookup) == name {
+				result = append(result, lookup)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown lookup distribution %q", name)
+		}
+	}
+	return
+}
+
+// forumPickerType pick forum IDs of point lookups or terms of searches, a nil picker picks nothing
+type forumPickerType struct {
+	ids  []string
+	next func() int
+}
+
+// newForumPicker return picker of lookup over ids, seed makes the picked sequence repeatable,
+// nil when lookup is lookupNone
+func newForumPicker(lookup lookupType, ids []string, seed int64) *forumPickerType {
+	if lookup == lookupNone || len(ids) < 1 {
+		return nil
+	}
+	random := rand.New(rand.NewSource(seed))
+	picker := &forumPickerType{ids: ids}
+	switch lookup {
+	case lookupRandom:
+		picker.next = func() int {
+			return random.Intn(len(ids))
+		}
+	case lookupZipf:
+		zipf := rand.NewZipf(random, zipfS, 1, uint64(len(ids)-1))
+		picker.next = func() int {
+			return int(zipf.Uint64())
+		}
+	default:
+		picker.next = func() int {
+			return 0
+		}
+	}
+	return picker
+}
+
+// Next return forum ID of the next lookup or term of the next search
+func (t *forumPickerType) Next() string {
+	if t == nil {
+		return ""
+	}
+	return t.ids[t.next()]
+}
+
+// loadForumIDs return all forum IDs of target in forumID order
+func loadForumIDs(ctx context.Context, target *targetType) (ids []string, err error) {
+	err = sqlx.SelectContext(ctx, target.DB, &ids, selectForumIDsQuery)
+	return
+}
+
+const selectForumIDsQuery = `
+SELECT forumID
+FROM forums
+ORDER BY forumID
+;`
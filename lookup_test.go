@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_forumPicker(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	lookups, err := parseLookups("fixed, zipf")
+	require.NoError(err)
+	assert.Equal([]lookupType{lookupFixed, lookupZipf}, lookups)
+	_, err = parseLookups("normal")
+	assert.Error(err)
+
+	ids := []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9"}
+
+	assert.Equal("", newForumPicker(lookupNone, ids, 1).Next())
+	assert.Equal("f0", newForumPicker(lookupFixed, ids, 1).Next())
+
+	for _, lookup := range []lookupType{lookupRandom, lookupZipf} {
+		picker, repeated := newForumPicker(lookup, ids, 1), newForumPicker(lookup, ids, 1)
+		counts := map[string]int{}
+		for n := 0; n < 1000; n++ {
+			id := picker.Next()
+			assert.Equal(id, repeated.Next(), "same seed picks the same sequence")
+			counts[id]++
+		}
+		assert.True(len(counts) > 1, lookup)
+		if lookup == lookupZipf {
+			assert.True(counts["f0"] > counts["f9"], counts)
+		}
+	}
+}
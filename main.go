@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+type commandType struct {
+	Usage string
+	Run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]commandType{
+	"seed": {
+		Usage: "insert seed data into all targets",
+		Run:   runSeed,
+	},
+	"index": {
+		Usage: "create indexes of latest activity and search workloads and counter columns of aggregates on all targets",
+		Run:   runIndex,
+	},
+	"bench": {
+		Usage: "run strategies against targets and report latency distribution",
+		Run:   runBench,
+	},
+	"compare": {
+		Usage: "compare two result files and fail on significant regression",
+		Run:   runCompare,
+	},
+	"report": {
+		Usage: "render result files as a static HTML report",
+		Run:   runReport,
+	},
+	"write-load": {
+		Usage: "run writers of bench -writers, controlled by bench through stdin and stdout",
+		Run:   runWriteLoad,
+	},
+}
+
+func main() {
+	ctx := context.Background()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := command.Run(ctx, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].Usage)
+	}
+}
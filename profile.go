@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+)
+
+// profilesType are profile files of one run case, empty when not captured
+type profilesType struct {
+	CPU  string `json:"cpu,omitempty"`
+	Heap string `json:"heap,omitempty"`
+	// Allocs is cumulative since process start, AllocsBase is taken before measuring,
+	// compare them with go tool pprof -base
+	Allocs     string `json:"allocs,omitempty"`
+	AllocsBase string `json:"allocsBase,omitempty"`
+}
+
+// profileRecorderType capture CPU profile while measuring and heap and allocation profiles after,
+// no-op when dir is empty
+type profileRecorderType struct {
+	dir      string
+	name     string
+	profiles profilesType
+	cpu      *os.File
+}
+
+// newProfileRecorder return recorder of run case on target with write load of config,
+// file names tell every swept setting apart, search terms are named by termsLabel
+func newProfileRecorder(dir string, target *targetType, runCase runCaseType, config runConfigType) *profileRecorderType {
+	name := target.Label() + "_" + runCase.String()
+	if runCase.Workload == workloadSearch {
+		name += " terms=" + termsLabel(target.SearchTerms)
+	}
+	if config.Write.Writers > 0 {
+		name += " " + config.Write.String()
+	}
+	return &profileRecorderType{
+		dir:  dir,
+		name: profileFileName(name),
+	}
+}
+
+// profileFileName replace characters not safe in file names
+func profileFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '@':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func (t *profileRecorderType) path(kind string) string {
+	return filepath.Join(t.dir, t.name+"."+kind+".pprof")
+}
+
+// writeProfile write named runtime profile after a GC, so heap profiles are up to date
+func (t *profileRecorderType) writeProfile(profile string, kind string) (path string, err error) {
+	runtime.GC()
+	path = t.path(kind)
+	err = writeFile(path, func(writer io.Writer) error {
+		return pprof.Lookup(profile).WriteTo(writer, 0)
+	})
+	return
+}
+
+func (t *profileRecorderType) Start() (err error) {
+	if t.dir == "" {
+		return
+	}
+	if err = os.MkdirAll(t.dir, 0755); err != nil {
+		return
+	}
+	if t.profiles.AllocsBase, err = t.writeProfile("allocs", "allocs-base"); err != nil {
+		return
+	}
+	t.profiles.CPU = t.path("cpu")
+	if t.cpu, err = os.Create(t.profiles.CPU); err != nil {
+		return
+	}
+	if err = pprof.StartCPUProfile(t.cpu); err != nil {
+		t.cpu.Close()
+		t.cpu = nil
+	}
+	return
+}
+
+// Close stop CPU profile when Stop is not reached, e.g. on error
+func (t *profileRecorderType) Close() {
+	if t.cpu != nil {
+		pprof.StopCPUProfile()
+		t.cpu.Close()
+		t.cpu = nil
+	}
+}
+
+func (t *profileRecorderType) Stop(result *runResultType) (err error) {
+	if t.cpu == nil {
+		return
+	}
+	pprof.StopCPUProfile()
+	if err = t.cpu.Close(); err != nil {
+		return
+	}
+	t.cpu = nil
+	if t.profiles.Heap, err = t.writeProfile("heap", "heap"); err != nil {
+		return
+	}
+	if t.profiles.Allocs, err = t.writeProfile("allocs", "allocs"); err != nil {
+		return
+	}
+	result.Profiles = t.profiles
+	return
+}
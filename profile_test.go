@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_profileRecorder(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	target := &targetType{Name: "pgsql", Version: "16.1"}
+	runCase := runCaseType{
+		Strategy: forumdb.Strategy{Name: "sub-query"},
+		TxOption: defaultTxOption,
+		Limit:    forumdb.DefaultLimit,
+	}
+
+	// no profile without dir
+	result := runResultType{}
+	recorder := newProfileRecorder("", target, runCase, runConfigType{})
+	require.NoError(recorder.Start())
+	require.NoError(recorder.Stop(&result))
+	assert.Equal(profilesType{}, result.Profiles)
+
+	dir := t.TempDir()
+	recorder = newProfileRecorder(dir, target, runCase, runConfigType{})
+	defer recorder.Close()
+	require.NoError(recorder.Start())
+	require.NoError(recorder.Stop(&result))
+	assert.Equal(dir+"/pgsql@16.1_sub-query_default_10x10x10.cpu.pprof", result.Profiles.CPU)
+	for _, path := range []string{result.Profiles.CPU, result.Profiles.Heap, result.Profiles.Allocs, result.Profiles.AllocsBase} {
+		info, err := os.Stat(path)
+		require.NoError(err, path)
+		assert.True(info.Size() > 0, path)
+	}
+
+	// every swept setting is part of the name
+	target.SearchTerms = []string{"ipsum", "lorem"}
+	runCase.Workload = workloadSearch
+	config := runConfigType{Write: writeConfigType{Writers: 2, Rate: 100, Mix: writeMixType{writePost: 1}}}
+	recorder = newProfileRecorder(dir, target, runCase, config)
+	assert.Equal("pgsql@16.1_search_sub-query_default_10x10x10_terms_2_"+strings.Split(termsLabel(target.SearchTerms), ":")[1]+"_writers_2_rate_100_mix_post_1", recorder.name)
+}
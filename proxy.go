@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// networkType is the simulated network between runner and target,
+// zero value means direct connection
+type networkType struct {
+	// RTT is the added round trip time, half of it delays each direction
+	RTT time.Duration `json:"rttNs"`
+	// Jitter is the max random deviation of RTT
+	Jitter time.Duration `json:"jitterNs"`
+	// Bandwidth limit each direction in bytes per second, 0 means unlimited
+	Bandwidth int64 `json:"bandwidth"`
+}
+
+func (t networkType) String() string {
+	if t == (networkType{}) {
+		return "direct"
+	}
+	result := "rtt=" + t.RTT.String()
+	if t.Jitter > 0 {
+		result += "±" + t.Jitter.String()
+	}
+	if t.Bandwidth > 0 {
+		result += fmt.Sprintf(",bw=%dB/s", t.Bandwidth)
+	}
+	return result
+}
+
+// parseNetworks return one network per comma separated RTT, sharing jitter and bandwidth,
+// a direct connection when rtts is empty and no other limit is set
+func parseNetworks(rtts string, jitter time.Duration, bandwidth int64) (networks []networkType, err error) {
+	names := splitNames(rtts)
+	if len(names) < 1 {
+		names = []string{"0"}
+	}
+	for _, name := range names {
+		rtt, err := time.ParseDuration(name)
+		if err != nil {
+			return nil, err
+		}
+		if rtt < 0 || jitter < 0 || bandwidth < 0 {
+			return nil, fmt.Errorf("invalid network rtt=%v jitter=%v bandwidth=%d, should not be negative", rtt, jitter, bandwidth)
+		}
+		networks = append(networks, networkType{
+			RTT:       rtt,
+			Jitter:    jitter,
+			Bandwidth: bandwidth,
+		})
+	}
+	return
+}
+
+// proxyDSN return upstream address of connURL and connURL connecting to proxyAddr instead,
+// MySQL DSN must use tcp(host:port), PostgreSQL must be an URL
+func proxyDSN(dialect forumdb.Dialect, connURL string, proxyAddr string) (upstream string, dsn string, err error) {
+	switch dialect {
+	case forumdb.MySQL:
+		start := strings.LastIndex(connURL, "@tcp(")
+		if start < 0 {
+			return "", "", fmt.Errorf("mysql dsn without tcp(host:port) address can't be proxied")
+		}
+		start += len("@tcp(")
+		end := strings.Index(connURL[start:], ")")
+		if end < 0 {
+			return "", "", fmt.Errorf("invalid mysql dsn address")
+		}
+		upstream = withDefaultPort(connURL[start:start+end], "3306")
+		dsn = connURL[:start] + proxyAddr + connURL[start+end:]
+	case forumdb.PGSQL:
+		u, errParse := url.Parse(connURL)
+		if errParse != nil {
+			return "", "", errParse
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return "", "", fmt.Errorf("pgsql dsn must be postgres:// url to be proxied")
+		}
+		host := u.Host
+		if host == "" {
+			host = "localhost"
+		}
+		upstream = withDefaultPort(host, "5432")
+		u.Host = proxyAddr
+		dsn = u.String()
+	default:
+		err = fmt.Errorf("unknown dialect %q", dialect)
+	}
+	return
+}
+
+func withDefaultPort(host string, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// latencyProxyType forward local TCP connections to upstream through the simulated network
+type latencyProxyType struct {
+	upstream string
+	network  networkType
+	listener net.Listener
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// startLatencyProxy listen on a random local port and forward to upstream
+func startLatencyProxy(upstream string, network networkType) (proxy *latencyProxyType, err error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	proxy = &latencyProxyType{
+		upstream: upstream,
+		network:  network,
+		listener: listener,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		conns:    map[net.Conn]bool{},
+	}
+	proxy.wg.Add(1)
+	go proxy.serve()
+	return
+}
+
+// Addr return the local address of proxy
+func (t *latencyProxyType) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// Close stop listening and close all forwarded connections
+func (t *latencyProxyType) Close() (err error) {
+	err = t.listener.Close()
+	t.mutex.Lock()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mutex.Unlock()
+	t.wg.Wait()
+	return
+}
+
+func (t *latencyProxyType) serve() {
+	defer t.wg.Done()
+	for {
+		client, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.handle(client)
+	}
+}
+
+func (t *latencyProxyType) track(conn net.Conn, add bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if add {
+		t.conns[conn] = true
+	} else {
+		delete(t.conns, conn)
+	}
+}
+
+func (t *latencyProxyType) handle(client net.Conn) {
+	defer t.wg.Done()
+	t.track(client, true)
+	defer t.track(client, false)
+	defer client.Close()
+
+	server, err := net.Dial("tcp", t.upstream)
+	if err != nil {
+		return
+	}
+	t.track(server, true)
+	defer t.track(server, false)
+	defer server.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		t.pipe(server, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		t.pipe(client, server)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+}
+
+// delay return one direction delay, half of RTT with random jitter
+func (t *latencyProxyType) delay() time.Duration {
+	delay := t.network.RTT / 2
+	if t.network.Jitter > 0 {
+		t.mutex.Lock()
+		delay += time.Duration(t.rand.Int63n(int64(t.network.Jitter)+1)) - t.network.Jitter/2
+		t.mutex.Unlock()
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+type proxyChunkType struct {
+	data []byte
+	due  time.Time
+}
+
+// pipe copy src to dst, every read chunk is delivered after delay in read order,
+// bandwidth delays a chunk until the previous one is transmitted
+func (t *latencyProxyType) pipe(dst net.Conn, src net.Conn) {
+	chunks := make(chan proxyChunkType, 256)
+	go func() {
+		defer close(chunks)
+		for {
+			buffer := make([]byte, 32*1024)
+			n, err := src.Read(buffer)
+			if n > 0 {
+				chunks <- proxyChunkType{data: buffer[:n], due: time.Now().Add(t.delay())}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	last := time.Time{}
+	failed := false
+	for chunk := range chunks {
+		if failed {
+			// drain until reader stops on closed connection
+			continue
+		}
+		deliver := chunk.due
+		if deliver.Before(last) {
+			deliver = last
+		}
+		if t.network.Bandwidth > 0 {
+			deliver = deliver.Add(time.Duration(int64(len(chunk.data)) * int64(time.Second) / t.network.Bandwidth))
+		}
+		time.Sleep(time.Until(deliver))
+		last = deliver
+		if _, err := dst.Write(chunk.data); err != nil {
+			failed = true
+		}
+	}
+}
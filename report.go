@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func runReport(ctx context.Context, args []string) (err error) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	outPath := flags.String("out", "report.html", "output HTML file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s report [flags] <result.json>...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return errors.New("report needs at least one result file")
+	}
+
+	files := []resultFileType{}
+	for _, path := range flags.Args() {
+		file, errRead := readResultFile(path)
+		if errRead != nil {
+			return errRead
+		}
+		files = append(files, file)
+	}
+
+	if err = writeFile(*outPath, func(writer io.Writer) error {
+		return writeReport(writer, newReport(flags.Args(), files))
+	}); err != nil {
+		return
+	}
+	consoleLogger.Logf("report written to %s\n", *outPath)
+	return
+}
+
+type reportType struct {
+	Generated time.Time
+	Files     []string
+	Targets   []reportTargetType
+	Charts    []reportChartType
+	Queries   []reportQueryType
+}
+
+type reportTargetType struct {
+	Label   string
+	Records []resultRecordType
+	Chart   template.HTML
+}
+
+type reportChartType struct {
+	Title string
+	Chart template.HTML
+}
+
+type reportQueryType struct {
+	Dialect  forumdb.Dialect
+	Strategy string
+	Queries  []string
+}
+
+// runLabel return the label of record inside its target
+func runLabel(record resultRecordType) string {
+	label := strategyLabel(record) + " " + record.Limit.String()
+	if record.Decoder != "" && record.Decoder != forumdb.DefaultDecoder.Name {
+		label += " decoder=" + record.Decoder
+	}
+	if record.Clients > 0 {
+		label += " clients=" + strconv.Itoa(record.Clients)
+	}
+	if record.PageMode != forumdb.PageNone {
+		label += " page=" + pageLabel(record)
+	}
+	if record.Lookup != "" {
+		label += " lookup=" + record.Lookup
+	}
+	if record.Aggregate != "" {
+		label += " aggregate=" + record.Aggregate
+	}
+	if record.Writes != nil {
+		label += " writers=" + strconv.Itoa(record.Writes.Writers)
+	}
+	return label
+}
+
+// strategyLabel return strategy of record with workload other than forums and transaction option
+func strategyLabel(record resultRecordType) string {
+	label := record.Strategy + " " + txOptionType{Isolation: record.Isolation, ReadOnly: record.ReadOnly}.String()
+	if record.Workload != "" && record.Workload != string(workloadForums) {
+		label = record.Workload + " " + label
+	}
+	return label
+}
+
+func pageLabel(record resultRecordType) string {
+	return string(record.PageMode) + ":" + strconv.Itoa(record.PageDepth)
+}
+
+func targetLabel(record resultRecordType) string {
+	return targetType{Name: record.Target, Version: record.ServerVersion, Network: record.Network}.Label()
+}
+
+func newReport(paths []string, files []resultFileType) (report reportType) {
+	report = reportType{
+		Generated: time.Now(),
+		Files:     paths,
+	}
+
+	records := []resultRecordType{}
+	for _, file := range files {
+		records = append(records, file.Records...)
+	}
+
+	targetIndex := map[string]int{}
+	queryIndex := map[string]bool{}
+	for _, record := range records {
+		label := targetLabel(record)
+		if _, ok := targetIndex[label]; !ok {
+			targetIndex[label] = len(report.Targets)
+			report.Targets = append(report.Targets, reportTargetType{Label: label})
+		}
+		target := &report.Targets[targetIndex[label]]
+		target.Records = append(target.Records, record)
+
+		queryKey := string(record.Dialect) + "/" + record.Strategy
+		if !queryIndex[queryKey] && len(record.Queries) > 0 {
+			queryIndex[queryKey] = true
+			report.Queries = append(report.Queries, reportQueryType{
+				Dialect:  record.Dialect,
+				Strategy: record.Strategy,
+				Queries:  record.Queries,
+			})
+		}
+	}
+	sort.Slice(report.Queries, func(i, j int) bool {
+		if report.Queries[i].Dialect != report.Queries[j].Dialect {
+			return report.Queries[i].Dialect < report.Queries[j].Dialect
+		}
+		return report.Queries[i].Strategy < report.Queries[j].Strategy
+	})
+
+	for i := range report.Targets {
+		target := &report.Targets[i]
+		rows := []barRowType{}
+		for _, record := range target.Records {
+			rows = append(rows, barRowType{
+				Label: runLabel(record),
+				P50:   record.Latency.P50,
+				P90:   record.Latency.P90,
+				P99:   record.Latency.P99,
+			})
+		}
+		target.Chart = svgBarChart(rows)
+
+		// write latency of the write load running alongside
+		rows = []barRowType{}
+		for _, record := range target.Records {
+			if record.Writes == nil {
+				continue
+			}
+			rows = append(rows, barRowType{
+				Label: runLabel(record),
+				P50:   record.Writes.Latency.P50,
+				P90:   record.Writes.Latency.P90,
+				P99:   record.Writes.Latency.P99,
+			})
+		}
+		if len(rows) > 0 {
+			report.Charts = append(report.Charts, reportChartType{
+				Title: target.Label + " write latency under read load",
+				Chart: svgBarChart(rows),
+			})
+		}
+
+		// latency by limit
+		series := newSeries(target.Records, func(record resultRecordType) (string, chartPointType) {
+			key := strategyLabel(record)
+			if record.Clients > 0 {
+				key += " clients=" + strconv.Itoa(record.Clients)
+			}
+			if record.PageMode != forumdb.PageNone {
+				key += " page=" + pageLabel(record)
+			}
+			return key, chartPointType{
+				X:     float64(record.Limit.Forums * record.Limit.Threads * record.Limit.Posts),
+				Y:     durationMS(record.Latency.P50),
+				Label: record.Limit.String(),
+			}
+		})
+		if hasCurve(series) {
+			report.Charts = append(report.Charts, reportChartType{
+				Title: target.Label + " p50 latency by limit",
+				Chart: svgLineChart("max returned posts (forums x threads x posts)", "p50 ms", series),
+			})
+		}
+
+		// throughput vs latency of load mode
+		series = newSeries(target.Records, func(record resultRecordType) (string, chartPointType) {
+			if record.Clients < 1 {
+				return "", chartPointType{}
+			}
+			key := strategyLabel(record) + " " + record.Limit.String()
+			if record.PageMode != forumdb.PageNone {
+				key += " page=" + pageLabel(record)
+			}
+			return key, chartPointType{
+				X:     record.Throughput,
+				Y:     durationMS(record.Latency.P50),
+				Label: "clients=" + strconv.Itoa(record.Clients),
+			}
+		})
+		if hasCurve(series) {
+			report.Charts = append(report.Charts, reportChartType{
+				Title: target.Label + " throughput vs p50 latency by clients",
+				Chart: svgLineChart("throughput ops/s", "p50 ms", series),
+			})
+		}
+
+		// latency by page depth of paged runs
+		series = newSeries(target.Records, func(record resultRecordType) (string, chartPointType) {
+			if record.PageMode == forumdb.PageNone {
+				return "", chartPointType{}
+			}
+			key := strategyLabel(record) + " " + record.Limit.String() + " " + string(record.PageMode)
+			if record.Clients > 0 {
+				key += " clients=" + strconv.Itoa(record.Clients)
+			}
+			return key, chartPointType{
+				X:     float64(record.PageDepth),
+				Y:     durationMS(record.Latency.P50),
+				Label: pageLabel(record),
+			}
+		})
+		if hasCurve(series) {
+			report.Charts = append(report.Charts, reportChartType{
+				Title: target.Label + " p50 latency by page depth",
+				Chart: svgLineChart("page depth", "p50 ms", series),
+			})
+		}
+	}
+
+	// latency by simulated round trip time of the same target
+	serverIndex := map[string]int{}
+	servers := [][]resultRecordType{}
+	for _, record := range records {
+		label := targetType{Name: record.Target, Version: record.ServerVersion}.Label()
+		if _, ok := serverIndex[label]; !ok {
+			serverIndex[label] = len(servers)
+			servers = append(servers, nil)
+		}
+		servers[serverIndex[label]] = append(servers[serverIndex[label]], record)
+	}
+	for _, serverRecords := range servers {
+		series := newSeries(serverRecords, func(record resultRecordType) (string, chartPointType) {
+			return runLabel(record), chartPointType{
+				X:     durationMS(record.Network.RTT),
+				Y:     durationMS(record.Latency.P50),
+				Label: record.Network.String(),
+			}
+		})
+		if hasCurve(series) {
+			label := targetType{Name: serverRecords[0].Target, Version: serverRecords[0].ServerVersion}.Label()
+			report.Charts = append(report.Charts, reportChartType{
+				Title: label + " p50 latency by round trip time",
+				Chart: svgLineChart("round trip time ms", "p50 ms", series),
+			})
+		}
+	}
+
+	// latency by dataset size across targets of the same dialect
+	for _, dialect := range []forumdb.Dialect{forumdb.MySQL, forumdb.PGSQL} {
+		dialectRecords := []resultRecordType{}
+		for _, record := range records {
+			if record.Dialect == dialect {
+				dialectRecords = append(dialectRecords, record)
+			}
+		}
+		series := newSeries(dialectRecords, func(record resultRecordType) (string, chartPointType) {
+			key := runLabel(record)
+			if record.Network != (networkType{}) {
+				key += " " + record.Network.String()
+			}
+			return key, chartPointType{
+				X:     float64(record.Shape.PostsCount),
+				Y:     durationMS(record.Latency.P50),
+				Label: targetLabel(record),
+			}
+		})
+		if hasCurve(series) {
+			report.Charts = append(report.Charts, reportChartType{
+				Title: string(dialect) + " p50 latency by dataset size",
+				Chart: svgLineChart("posts in dataset", "p50 ms", series),
+			})
+		}
+	}
+	return
+}
+
+func writeReport(writer io.Writer, report reportType) error {
+	return reportTemplate.Execute(writer, report)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return strconv.FormatFloat(durationMS(d), 'f', 3, 64)
+	},
+	"run": runLabel,
+	"serverStats": func(record resultRecordType) string {
+		if record.ServerStats == nil {
+			return "-"
+		}
+		return record.ServerStats.String(record.Dialect)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-db-benchmark-app-sub-query report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+pre { background: #f6f6f6; padding: 0.8em; overflow-x: auto; }
+svg text { font-size: 11px; }
+</style>
+</head>
+<body>
+<h1>Benchmark report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05"}} from {{range $i, $file := .Files}}{{if $i}}, {{end}}<code>{{$file}}</code>{{end}}</p>
+
+{{range .Targets}}
+<h2>{{.Label}}</h2>
+{{.Chart}}
+<table>
+<tr><th>run</th><th>iterations</th><th>p50 ms</th><th>p90 ms</th><th>p99 ms</th><th>max ms</th><th>ops/s</th><th>allocs/op</th><th>bytes/op</th><th>dataset posts</th><th>first byte ms</th><th>last row ms</th><th>decode ms</th><th>assemble ms</th><th>server stats/op</th></tr>
+{{range .Records}}<tr><td>{{run .}}</td><td>{{.Iterations}}</td><td>{{ms .Latency.P50}}</td><td>{{ms .Latency.P90}}</td><td>{{ms .Latency.P99}}</td><td>{{ms .Latency.Max}}</td><td>{{printf "%.2f" .Throughput}}</td><td>{{.AllocsPerOp}}</td><td>{{.BytesPerOp}}</td><td>{{.Shape.PostsCount}}</td><td>{{ms .Phases.FirstByte}}</td><td>{{ms .Phases.LastRow}}</td><td>{{ms .Phases.Decode}}</td><td>{{ms .Phases.Assemble}}</td><td>{{serverStats .}}</td></tr>
+{{end}}</table>
+{{range .Records}}{{if .Plans}}<details>
+<summary>plans of {{run .}}</summary>
+{{range .Plans}}<h4>{{.Format}}</h4>
+<pre>{{.Query}}</pre>
+<pre>{{.Plan}}</pre>
+{{end}}</details>
+{{end}}{{end}}
+{{end}}
+
+{{if .Charts}}<h2>Scaling</h2>{{end}}
+{{range .Charts}}
+<h3>{{.Title}}</h3>
+{{.Chart}}
+{{end}}
+
+<h2>Queries</h2>
+{{range .Queries}}
+<h3>{{.Dialect}} {{.Strategy}}</h3>
+{{range .Queries}}<pre>{{.}}</pre>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type chartPointType struct {
+	X     float64
+	Y     float64
+	Label string
+}
+
+type chartSeriesType struct {
+	Name   string
+	Points []chartPointType
+}
+
+// newSeries group records into series by the key returned from point, empty key is skipped
+func newSeries(records []resultRecordType, point func(record resultRecordType) (string, chartPointType)) (series []chartSeriesType) {
+	index := map[string]int{}
+	for _, record := range records {
+		key, p := point(record)
+		if key == "" {
+			continue
+		}
+		if _, ok := index[key]; !ok {
+			index[key] = len(series)
+			series = append(series, chartSeriesType{Name: key})
+		}
+		series[index[key]].Points = append(series[index[key]].Points, p)
+	}
+	for i := range series {
+		points := series[i].Points
+		sort.Slice(points, func(a, b int) bool {
+			return points[a].X < points[b].X
+		})
+	}
+	return
+}
+
+// hasCurve return true when any series has points at different x
+func hasCurve(series []chartSeriesType) bool {
+	for _, s := range series {
+		for _, p := range s.Points {
+			if p.X != s.Points[0].X {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+type barRowType struct {
+	Label string
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+// svgBarChart draw p50 bars with p90 tick and p99 whisker
+func svgBarChart(rows []barRowType) template.HTML {
+	const (
+		labelWidth = 360
+		barWidth   = 440
+		rowHeight  = 22
+		top        = 10
+	)
+	maxValue := 0.0
+	for _, row := range rows {
+		maxValue = math.Max(maxValue, durationMS(row.P99))
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	scale := func(d time.Duration) float64 {
+		return labelWidth + durationMS(d)/maxValue*barWidth
+	}
+
+	height := top*2 + rowHeight*len(rows)
+	buffer := bytes.Buffer{}
+	fmt.Fprintf(&buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, labelWidth+barWidth+120, height)
+	for i, row := range rows {
+		y := top + i*rowHeight
+		mid := float64(y) + rowHeight/2
+		color := chartColors[i%len(chartColors)]
+		fmt.Fprintf(&buffer, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, labelWidth-6, mid, html.EscapeString(row.Label))
+		fmt.Fprintf(&buffer, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"><title>p50 %.3f ms</title></rect>`, labelWidth, y+3, scale(row.P50)-labelWidth, rowHeight-6, color, durationMS(row.P50))
+		fmt.Fprintf(&buffer, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`, scale(row.P50), mid, scale(row.P99), mid)
+		fmt.Fprintf(&buffer, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#333"><title>p90 %.3f ms</title></line>`, scale(row.P90), y+6, scale(row.P90), y+rowHeight-6, durationMS(row.P90))
+		fmt.Fprintf(&buffer, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#333"><title>p99 %.3f ms</title></line>`, scale(row.P99), y+4, scale(row.P99), y+rowHeight-4, durationMS(row.P99))
+		fmt.Fprintf(&buffer, `<text x="%.1f" y="%.1f" dominant-baseline="middle">%.3f ms</text>`, scale(row.P99)+6, mid, durationMS(row.P50))
+	}
+	buffer.WriteString(`</svg>`)
+	return template.HTML(buffer.String())
+}
+
+// svgLineChart draw series as lines with point markers and a legend
+func svgLineChart(xLabel string, yLabel string, series []chartSeriesType) template.HTML {
+	const (
+		width       = 640
+		height      = 300
+		left        = 70
+		right       = 20
+		top         = 20
+		bottom      = 50
+		legendWidth = 360
+	)
+	minX, maxX, maxY := math.Inf(1), math.Inf(-1), 0.0
+	for _, s := range series {
+		for _, p := range s.Points {
+			minX = math.Min(minX, p.X)
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	if maxX <= minX {
+		maxX = minX + 1
+	}
+	if maxY <= 0 {
+		maxY = 1
+	}
+	maxY *= 1.1
+	scaleX := func(x float64) float64 {
+		return left + (x-minX)/(maxX-minX)*(width-left-right)
+	}
+	scaleY := func(y float64) float64 {
+		return top + (1-y/maxY)*(height-top-bottom)
+	}
+
+	buffer := bytes.Buffer{}
+	fmt.Fprintf(&buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, width+legendWidth, height)
+	fmt.Fprintf(&buffer, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, left, height-bottom, width-right, height-bottom)
+	fmt.Fprintf(&buffer, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, left, top, left, height-bottom)
+	for i := 0; i <= 4; i++ {
+		x := minX + (maxX-minX)*float64(i)/4
+		y := maxY * float64(i) / 4
+		fmt.Fprintf(&buffer, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, scaleX(x), height-bottom+16, strconv.FormatFloat(x, 'g', 4, 64))
+		fmt.Fprintf(&buffer, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, left-6, scaleY(y), strconv.FormatFloat(y, 'g', 4, 64))
+		fmt.Fprintf(&buffer, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`, left+1, scaleY(y), width-right, scaleY(y))
+	}
+	fmt.Fprintf(&buffer, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, (left+width-right)/2, height-12, html.EscapeString(xLabel))
+	fmt.Fprintf(&buffer, `<text x="14" y="%d" text-anchor="middle" transform="rotate(-90 14 %d)">%s</text>`, (top+height-bottom)/2, (top+height-bottom)/2, html.EscapeString(yLabel))
+
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		path := bytes.Buffer{}
+		for j, p := range s.Points {
+			command := "L"
+			if j == 0 {
+				command = "M"
+			}
+			fmt.Fprintf(&path, "%s%.1f %.1f ", command, scaleX(p.X), scaleY(p.Y))
+		}
+		fmt.Fprintf(&buffer, `<path d="%s" fill="none" stroke="%s" stroke-width="2"/>`, path.String(), color)
+		for _, p := range s.Points {
+			fmt.Fprintf(&buffer, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s %s: %.3f</title></circle>`,
+				scaleX(p.X), scaleY(p.Y), color, html.EscapeString(s.Name), html.EscapeString(p.Label), p.Y)
+		}
+		legendY := top + i*16
+		fmt.Fprintf(&buffer, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`, width+10, legendY, color)
+		fmt.Fprintf(&buffer, `<text x="%d" y="%d">%s</text>`, width+26, legendY+9, html.EscapeString(s.Name))
+	}
+	buffer.WriteString(`</svg>`)
+	return template.HTML(buffer.String())
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_newReport(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	record := func(strategy string, limit forumdb.Limit, p50 time.Duration) resultRecordType {
+		return resultRecordType{
+			Target:        "pgsql_16",
+			Dialect:       forumdb.PGSQL,
+			ServerVersion: "16.1",
+			Strategy:      strategy,
+			Queries:       []string{"SELECT <" + strategy + ">"},
+			Isolation:     "default",
+			Limit:         limit,
+			Latency:       latencyType{P50: p50, P90: 2 * p50, P99: 3 * p50, Max: 4 * p50},
+		}
+	}
+	file := resultFileType{
+		Records: []resultRecordType{
+			record("sub-query", forumdb.Limit{Forums: 10, Threads: 10, Posts: 10}, time.Millisecond),
+			record("sub-query", forumdb.Limit{Forums: 10, Threads: 100, Posts: 10}, 5*time.Millisecond),
+			record("lateral", forumdb.Limit{Forums: 10, Threads: 10, Posts: 10}, 2*time.Millisecond),
+		},
+	}
+	file.Records[2].Plans = []planType{{Query: "SELECT <lateral>", Format: "analyze-json", Plan: `[{"Plan": {"Node Type": "Limit"}}]`}}
+
+	report := newReport([]string{"a.json"}, []resultFileType{file})
+	require.Len(report.Targets, 1)
+	assert.Equal("pgsql_16@16.1", report.Targets[0].Label)
+	assert.Len(report.Targets[0].Records, 3)
+	require.Len(report.Charts, 1)
+	assert.Contains(report.Charts[0].Title, "by limit")
+	require.Len(report.Queries, 2)
+	assert.Equal("lateral", report.Queries[0].Strategy)
+
+	buffer := bytes.Buffer{}
+	require.NoError(writeReport(&buffer, report))
+	assert.Contains(buffer.String(), "<svg")
+	assert.Contains(buffer.String(), "sub-query default 10x100x10")
+	assert.Contains(buffer.String(), "SELECT &lt;sub-query&gt;")
+	assert.Contains(buffer.String(), "plans of lateral default 10x10x10")
+	assert.Contains(buffer.String(), "&#34;Node Type&#34;: &#34;Limit&#34;")
+}
+
+func Test_newReportPageDepth(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	record := func(strategy string, mode forumdb.PageMode, depth int, p50 time.Duration) resultRecordType {
+		return resultRecordType{
+			Target:    "mysql_8",
+			Dialect:   forumdb.MySQL,
+			Strategy:  strategy,
+			Isolation: "default",
+			Limit:     forumdb.DefaultLimit,
+			PageMode:  mode,
+			PageDepth: depth,
+			Latency:   latencyType{P50: p50},
+		}
+	}
+	file := resultFileType{
+		Records: []resultRecordType{
+			record("sub-query", forumdb.PageKeyset, 0, time.Millisecond),
+			record("sub-query", forumdb.PageKeyset, 100, time.Millisecond),
+			record("sub-query", forumdb.PageOffset, 0, time.Millisecond),
+			record("sub-query", forumdb.PageOffset, 100, 3*time.Millisecond),
+		},
+	}
+
+	report := newReport([]string{"a.json"}, []resultFileType{file})
+	require.Len(report.Charts, 1)
+	assert.Contains(report.Charts[0].Title, "by page depth")
+	assert.Equal("sub-query default 10x10x10 page=offset:100", runLabel(file.Records[3]))
+}
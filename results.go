@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// resultFileType is the content of a JSON result file
+type resultFileType struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Records   []resultRecordType `json:"records"`
+}
+
+// resultRecordType is one strategy run, durations are in nanoseconds
+type resultRecordType struct {
+	Timestamp     time.Time       `json:"timestamp"`
+	Target        string          `json:"target"`
+	Dialect       forumdb.Dialect `json:"dialect"`
+	ServerVersion string          `json:"serverVersion"`
+	Network       networkType     `json:"network"`
+	Strategy      string          `json:"strategy"`
+	Queries       []string        `json:"queries"`
+	Isolation     string          `json:"isolation"`
+	ReadOnly      bool            `json:"readOnly"`
+	Clients       int             `json:"clients"`
+	Shape         dataShapeType   `json:"shape"`
+	Limit         forumdb.Limit   `json:"limit"`
+	Decoder       string          `json:"decoder"`
+	// PageMode and PageDepth are the loaded page of forums, empty mode means the first forums
+	PageMode  forumdb.PageMode `json:"pageMode,omitempty"`
+	PageDepth int              `json:"pageDepth,omitempty"`
+	// Workload is what every iteration loads, empty in results written before workloads means forums
+	Workload string `json:"workload,omitempty"`
+	// Lookup is the forum distribution of point lookups or term distribution of searches, empty for other strategies
+	Lookup string `json:"lookup,omitempty"`
+	// Aggregate is how thread and post counts were loaded, empty when not loaded
+	Aggregate string `json:"aggregate,omitempty"`
+	// Terms are the searched terms of search workload, sorted
+	Terms        []string      `json:"terms,omitempty"`
+	Iterations   int           `json:"iterations"`
+	Elapsed      time.Duration `json:"elapsedNs"`
+	Throughput   float64       `json:"throughput"`
+	Latency      latencyType   `json:"latency"`
+	PoolWait     time.Duration `json:"poolWaitNs"`
+	AllocsPerOp  uint64        `json:"allocsPerOp"`
+	BytesPerOp   uint64        `json:"bytesPerOp"`
+	GCPerOp      float64       `json:"gcPerOp"`
+	GCPausePerOp time.Duration `json:"gcPausePerOpNs"`
+	// Phases is time of each phase per iteration
+	Phases forumdb.Phases `json:"phases"`
+	// ServerStats is server statement counters per iteration
+	ServerStats *serverStatsType `json:"serverStats,omitempty"`
+	Plans       []planType       `json:"plans,omitempty"`
+	Profiles    profilesType     `json:"profiles"`
+	Samples     []int64          `json:"samplesNs"`
+	// Writes is the write load running alongside the strategy, nil without write load
+	Writes *writeRecordType `json:"writes,omitempty"`
+}
+
+// writeRecordType is the write load of a strategy run, durations are in nanoseconds
+type writeRecordType struct {
+	Writers    int            `json:"writers"`
+	Rate       float64        `json:"rate"`
+	Mix        string         `json:"mix"`
+	Iterations int            `json:"iterations"`
+	Elapsed    time.Duration  `json:"elapsedNs"`
+	Throughput float64        `json:"throughput"`
+	Latency    latencyType    `json:"latency"`
+	Errors     int            `json:"errors"`
+	Ops        map[string]int `json:"ops"`
+}
+
+func newWriteRecord(writes *writeResultType) *writeRecordType {
+	if writes == nil {
+		return nil
+	}
+	ops := map[string]int{}
+	for kind, count := range writes.Ops {
+		ops[string(kind)] = count
+	}
+	return &writeRecordType{
+		Writers:    writes.Writers,
+		Rate:       writes.Rate,
+		Mix:        writes.Mix.String(),
+		Iterations: len(writes.Samples),
+		Elapsed:    writes.Elapsed,
+		Throughput: writes.Throughput(),
+		Latency:    writes.Latency(),
+		Errors:     writes.Errors,
+		Ops:        ops,
+	}
+}
+
+func newResultRecord(result runResultType, timestamp time.Time) resultRecordType {
+	samples := make([]int64, 0, len(result.Samples))
+	for _, sample := range result.Samples {
+		samples = append(samples, int64(sample))
+	}
+	return resultRecordType{
+		Timestamp:     timestamp,
+		Target:        result.Target.Name,
+		Dialect:       result.Target.Dialect,
+		ServerVersion: result.Target.Version,
+		Network:       result.Target.Network,
+		Strategy:      result.Strategy.Name,
+		Queries:       result.Strategy.Queries,
+		Isolation:     result.TxOption.Isolation,
+		ReadOnly:      result.TxOption.ReadOnly,
+		Clients:       result.Clients,
+		Shape:         result.Target.Shape,
+		Limit:         result.Limit,
+		Decoder:       result.Decoder.Name,
+		PageMode:      result.Page.Mode,
+		PageDepth:     result.Page.Depth,
+		Workload:      string(result.Workload),
+		Lookup:        string(result.Lookup),
+		Aggregate:     string(result.Aggregate),
+		Terms:         result.terms(),
+		Iterations:    len(result.Samples),
+		Elapsed:       result.Elapsed,
+		Throughput:    result.Throughput(),
+		Latency:       result.Latency(),
+		PoolWait:      result.PoolWait,
+		AllocsPerOp:   result.AllocsPerOp(),
+		BytesPerOp:    result.BytesPerOp(),
+		GCPerOp:       result.GCCyclesPerOp(),
+		GCPausePerOp:  result.GCPausePerOp(),
+		Phases:        result.PhasesPerOp(),
+		ServerStats:   result.ServerStatsPerOp(),
+		Plans:         result.Plans,
+		Profiles:      result.Profiles,
+		Samples:       samples,
+		Writes:        newWriteRecord(result.Writes),
+	}
+}
+
+// SampleDurations return samples as durations
+func (t resultRecordType) SampleDurations() []time.Duration {
+	samples := make([]time.Duration, 0, len(t.Samples))
+	for _, sample := range t.Samples {
+		samples = append(samples, time.Duration(sample))
+	}
+	return samples
+}
+
+var resultCSVHeader = []string{
+	"timestamp",
+	"target",
+	"dialect",
+	"server_version",
+	"rtt_ns",
+	"jitter_ns",
+	"bandwidth",
+	"strategy",
+	"isolation",
+	"read_only",
+	"clients",
+	"shape_forums",
+	"shape_threads",
+	"shape_posts",
+	"limit_forums",
+	"limit_threads",
+	"limit_posts",
+	"decoder",
+	"iterations",
+	"elapsed_ns",
+	"throughput",
+	"min_ns",
+	"mean_ns",
+	"p50_ns",
+	"p90_ns",
+	"p99_ns",
+	"max_ns",
+	"pool_wait_ns",
+	"allocs_per_op",
+	"bytes_per_op",
+	"first_byte_ns",
+	"last_row_ns",
+	"decode_ns",
+	"assemble_ns",
+	"server_calls_per_op",
+	"server_rows_per_op",
+	"server_shared_blks_hit_per_op",
+	"server_shared_blks_read_per_op",
+	"server_temp_blks_read_per_op",
+	"server_temp_blks_written_per_op",
+	"server_rows_examined_per_op",
+	"server_tmp_tables_per_op",
+	"server_tmp_disk_tables_per_op",
+	"server_sort_merge_passes_per_op",
+	"gc_per_op",
+	"gc_pause_per_op_ns",
+	"page_mode",
+	"page_depth",
+	"lookup",
+	"workload",
+	"writers",
+	"write_rate",
+	"write_mix",
+	"write_iterations",
+	"write_throughput",
+	"write_errors",
+	"write_p50_ns",
+	"write_p99_ns",
+	"aggregate",
+	"terms",
+}
+
+func (t resultRecordType) csvRow() []string {
+	row := []string{
+		t.Timestamp.Format(time.RFC3339),
+		t.Target,
+		string(t.Dialect),
+		t.ServerVersion,
+		strconv.FormatInt(int64(t.Network.RTT), 10),
+		strconv.FormatInt(int64(t.Network.Jitter), 10),
+		strconv.FormatInt(t.Network.Bandwidth, 10),
+		t.Strategy,
+		t.Isolation,
+		strconv.FormatBool(t.ReadOnly),
+		strconv.Itoa(t.Clients),
+		strconv.FormatInt(t.Shape.ForumsCount, 10),
+		strconv.FormatInt(t.Shape.ThreadsCount, 10),
+		strconv.FormatInt(t.Shape.PostsCount, 10),
+		strconv.Itoa(t.Limit.Forums),
+		strconv.Itoa(t.Limit.Threads),
+		strconv.Itoa(t.Limit.Posts),
+		t.Decoder,
+		strconv.Itoa(t.Iterations),
+		strconv.FormatInt(int64(t.Elapsed), 10),
+		strconv.FormatFloat(t.Throughput, 'f', 3, 64),
+		strconv.FormatInt(int64(t.Latency.Min), 10),
+		strconv.FormatInt(int64(t.Latency.Mean), 10),
+		strconv.FormatInt(int64(t.Latency.P50), 10),
+		strconv.FormatInt(int64(t.Latency.P90), 10),
+		strconv.FormatInt(int64(t.Latency.P99), 10),
+		strconv.FormatInt(int64(t.Latency.Max), 10),
+		strconv.FormatInt(int64(t.PoolWait), 10),
+		strconv.FormatUint(t.AllocsPerOp, 10),
+		strconv.FormatUint(t.BytesPerOp, 10),
+		strconv.FormatInt(int64(t.Phases.FirstByte), 10),
+		strconv.FormatInt(int64(t.Phases.LastRow), 10),
+		strconv.FormatInt(int64(t.Phases.Decode), 10),
+		strconv.FormatInt(int64(t.Phases.Assemble), 10),
+	}
+	if t.ServerStats == nil {
+		row = append(row, make([]string, 10)...)
+	} else {
+		for _, value := range []float64{
+			t.ServerStats.Calls,
+			t.ServerStats.Rows,
+			t.ServerStats.SharedBlksHit,
+			t.ServerStats.SharedBlksRead,
+			t.ServerStats.TempBlksRead,
+			t.ServerStats.TempBlksWritten,
+			t.ServerStats.RowsExamined,
+			t.ServerStats.TmpTables,
+			t.ServerStats.TmpDiskTables,
+			t.ServerStats.SortMergePasses,
+		} {
+			row = append(row, strconv.FormatFloat(value, 'f', 3, 64))
+		}
+	}
+	row = append(row,
+		strconv.FormatFloat(t.GCPerOp, 'f', 3, 64),
+		strconv.FormatInt(int64(t.GCPausePerOp), 10),
+		string(t.PageMode),
+		strconv.Itoa(t.PageDepth),
+		t.Lookup,
+		t.Workload,
+	)
+	if t.Writes == nil {
+		row = append(row, make([]string, 8)...)
+	} else {
+		row = append(row,
+			strconv.Itoa(t.Writes.Writers),
+			strconv.FormatFloat(t.Writes.Rate, 'f', 3, 64),
+			t.Writes.Mix,
+			strconv.Itoa(t.Writes.Iterations),
+			strconv.FormatFloat(t.Writes.Throughput, 'f', 3, 64),
+			strconv.Itoa(t.Writes.Errors),
+			strconv.FormatInt(int64(t.Writes.Latency.P50), 10),
+			strconv.FormatInt(int64(t.Writes.Latency.P99), 10),
+		)
+	}
+	return append(row, t.Aggregate, strings.Join(t.Terms, " "))
+}
+
+// writeResultFiles write file as <dir>/<timestamp>.json and <dir>/<timestamp>.csv,
+// return paths of written files
+func writeResultFiles(dir string, file resultFileType) (paths []string, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	base := filepath.Join(dir, file.Timestamp.Format("20060102-150405"))
+
+	jsonPath := base + ".json"
+	if err = writeFile(jsonPath, func(writer io.Writer) error {
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(file)
+	}); err != nil {
+		return
+	}
+	paths = append(paths, jsonPath)
+
+	csvPath := base + ".csv"
+	if err = writeFile(csvPath, func(writer io.Writer) error {
+		return writeResultCSV(writer, file.Records)
+	}); err != nil {
+		return
+	}
+	paths = append(paths, csvPath)
+	return
+}
+
+func writeResultCSV(writer io.Writer, records []resultRecordType) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(resultCSVHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := csvWriter.Write(record.csvRow()); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeFile(path string, write func(writer io.Writer) error) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+	return write(file)
+}
+
+// readResultFile read JSON result file
+func readResultFile(path string) (result resultFileType, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&result)
+	return
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_writeResultFiles(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	dir, err := ioutil.TempDir("", "results")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	timestamp := time.Date(2018, 9, 4, 12, 0, 0, 0, time.UTC)
+	result := runResultType{
+		Target: &targetType{
+			Name:    "pgsql_16",
+			Dialect: forumdb.PGSQL,
+			Version: "16.1",
+			Shape:   dataShapeType{ForumsCount: 100, ThreadsCount: 100000, PostsCount: 1000000},
+		},
+		runCaseType: runCaseType{
+			Strategy: forumdb.Strategy{Dialect: forumdb.PGSQL, Name: "sub-query"},
+			TxOption: defaultTxOption,
+			Limit:    forumdb.DefaultLimit,
+		},
+		Samples: []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond},
+		Elapsed: 6 * time.Millisecond,
+		MemStats: []memStatsType{
+			{Iterations: 1, Allocs: 5},
+			{Iterations: 1, Allocs: 10},
+			{Iterations: 1, Allocs: 15},
+		},
+		ServerStats: &serverStatsType{
+			Calls:         333,
+			SharedBlksHit: 60,
+		},
+	}
+	file := resultFileType{
+		Timestamp: timestamp,
+		Records:   []resultRecordType{newResultRecord(result, timestamp)},
+	}
+
+	paths, err := writeResultFiles(dir, file)
+	require.NoError(err)
+	require.Len(paths, 2)
+
+	read, err := readResultFile(paths[0])
+	require.NoError(err)
+	require.Len(read.Records, 1)
+	record := read.Records[0]
+	assert.Equal("pgsql_16", record.Target)
+	assert.Equal("16.1", record.ServerVersion)
+	assert.Equal(forumdb.DefaultLimit, record.Limit)
+	assert.Equal(int64(100000), record.Shape.ThreadsCount)
+	assert.Equal(2*time.Millisecond, record.Latency.P50)
+	assert.Equal(uint64(10), record.AllocsPerOp)
+	assert.Equal(result.Samples, record.SampleDurations())
+	require.NotNil(record.ServerStats)
+	assert.Equal(111.0, record.ServerStats.Calls)
+	assert.Equal(20.0, record.ServerStats.SharedBlksHit)
+
+	data, err := ioutil.ReadFile(paths[1])
+	require.NoError(err)
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(err)
+	require.Len(rows, 2)
+	assert.Equal(resultCSVHeader, rows[0])
+	assert.Len(rows[1], len(resultCSVHeader))
+	assert.Equal("sub-query", rows[1][7])
+}
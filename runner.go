@@ -0,0 +1,533 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// runConfigType control how long a strategy runs
+type runConfigType struct {
+	// Iterations run fixed iterations when > 0, otherwise run for Duration
+	Iterations int
+	Duration   time.Duration
+	// Warmup iterations are run before measuring
+	Warmup int
+	// ProfileDir is the directory of CPU, heap and allocation profiles of every run case, no profile when empty
+	ProfileDir string
+	// Write is the write load running alongside every run case, no write load when Writers is 0
+	Write writeConfigType
+}
+
+// runCaseType is one combination of swept settings to run on a target
+type runCaseType struct {
+	// Workload is the workload Strategy belongs to, empty means workloadForums
+	Workload workloadType
+	Strategy forumdb.Strategy
+	TxOption txOptionType
+	Limit    forumdb.Limit
+	Decoder  forumdb.Decoder
+	// Clients is the concurrent client count of load mode,
+	// 0 means one client running all iterations in one transaction
+	Clients int
+	Page    pageType
+	// Lookup is the forum distribution of point lookup strategies or term distribution of search strategies, empty for other strategies
+	Lookup lookupType
+	// Aggregate is how Strategy loads thread and post counts, Strategy is already wrapped by forumdb.WithAggregate
+	Aggregate forumdb.Aggregate
+}
+
+// pageType is the page of forums a run case loads, zero value loads the first forums
+type pageType struct {
+	// Page is resolved by resolve before running
+	forumdb.Page
+	// Depth is the page number counted from 0
+	Depth int
+}
+
+func (t pageType) String() string {
+	if t.Mode == forumdb.PageNone {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", t.Mode, t.Depth)
+}
+
+// resolve return page with keyset cursor or offset of page depth on target
+func (t pageType) resolve(ctx context.Context, target *targetType, limit forumdb.Limit) (page pageType, err error) {
+	page = t
+	page.Page, err = forumdb.NewPage(ctx, target.DB, target.Dialect, t.Mode, t.Depth, limit.Forums)
+	return
+}
+
+func (t runCaseType) String() string {
+	result := fmt.Sprintf("%s %s %s", t.Strategy.Name, t.TxOption, t.Limit)
+	if t.Workload != "" && t.Workload != workloadForums {
+		result = string(t.Workload) + " " + result
+	}
+	if t.Decoder.Name != "" && t.Decoder.Name != forumdb.DefaultDecoder.Name {
+		result += " decoder=" + t.Decoder.Name
+	}
+	if t.Clients > 0 {
+		result += fmt.Sprintf(" clients=%d", t.Clients)
+	}
+	if page := t.Page.String(); page != "" {
+		result += " page=" + page
+	}
+	if t.Lookup != lookupNone {
+		result += " lookup=" + string(t.Lookup)
+	}
+	if t.Aggregate != forumdb.AggregateNone {
+		result += " aggregate=" + string(t.Aggregate)
+	}
+	return result
+}
+
+// request return Select input of one iteration, picker pick the forum of point lookups or the search term
+func (t runCaseType) request(phases *forumdb.Phases, picker *forumPickerType) forumdb.Request {
+	request := forumdb.Request{
+		Limit:   t.Limit,
+		Page:    t.Page.Page,
+		Decoder: t.Decoder,
+		Phases:  phases,
+	}
+	if t.Workload == workloadSearch {
+		request.Term = picker.Next()
+	} else {
+		request.ForumID = picker.Next()
+	}
+	return request
+}
+
+// picker return picker of Lookup over forum IDs of target, or search terms of target in search workload
+func (t runCaseType) picker(target *targetType, seed int64) *forumPickerType {
+	if t.Workload == workloadSearch {
+		return newForumPicker(t.Lookup, target.SearchTerms, seed)
+	}
+	return newForumPicker(t.Lookup, target.ForumIDs, seed)
+}
+
+// runCases return every combination of settings, nil clients means sequential mode,
+// nil decoders means default decoder, nil pages means the first forums,
+// lookups are distributions of point lookup strategies, nil for other strategies,
+// nil aggregates means no count, every strategy is wrapped by each aggregate it supports
+func runCases(strategies []forumdb.Strategy, txOpts []txOptionType, limits []forumdb.Limit, decoders []forumdb.Decoder, clients []int, pages []pageType, lookups []lookupType, aggregates []forumdb.Aggregate) (cases []runCaseType) {
+	if len(clients) < 1 {
+		clients = []int{0}
+	}
+	if len(pages) < 1 {
+		pages = []pageType{{}}
+	}
+	if len(lookups) < 1 {
+		lookups = []lookupType{lookupNone}
+	}
+	if len(decoders) < 1 {
+		decoders = []forumdb.Decoder{forumdb.DefaultDecoder}
+	}
+	if len(aggregates) < 1 {
+		aggregates = []forumdb.Aggregate{forumdb.AggregateNone}
+	}
+	for _, baseStrategy := range strategies {
+		for _, aggregate := range aggregates {
+			if !aggregate.Supports(baseStrategy) {
+				continue
+			}
+			strategy := forumdb.WithAggregate(baseStrategy, aggregate)
+			for _, txOpt := range txOpts {
+				for _, limit := range limits {
+					for _, decoder := range decoders {
+						for _, clientCount := range clients {
+							for _, page := range pages {
+								for _, lookup := range lookups {
+									cases = append(cases, runCaseType{
+										Strategy:  strategy,
+										TxOption:  txOpt,
+										Limit:     limit,
+										Decoder:   decoder,
+										Clients:   clientCount,
+										Page:      page,
+										Lookup:    lookup,
+										Aggregate: aggregate,
+									})
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// runResultType keep every measured iteration of a strategy run
+type runResultType struct {
+	runCaseType
+	Target  *targetType
+	Samples []time.Duration
+	Elapsed time.Duration
+	// PoolWait is the total time clients waited for a pooled connection
+	PoolWait time.Duration
+	// MemStats are heap allocations and GC of every measured iteration in sequential mode,
+	// of all iterations of all clients in load mode, as clients share the heap
+	MemStats []memStatsType
+	// Profiles are profile files captured while measuring
+	Profiles profilesType
+	// ServerStats is the increase of server statement counters while measuring, nil when not available
+	ServerStats *serverStatsType
+	// Plans are the explained statements issued by strategy
+	Plans []planType
+	// Phases is the total time of each phase while measuring
+	Phases forumdb.Phases
+	// Writes is the write load running alongside the case, nil without write load
+	Writes *writeResultType
+}
+
+// Latency return latency distribution of samples
+func (t runResultType) Latency() latencyType {
+	return newLatency(t.Samples)
+}
+
+// Throughput return iterations per second
+func (t runResultType) Throughput() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(len(t.Samples)) / t.Elapsed.Seconds()
+}
+
+// terms return search terms of target in search workload, nil for other workloads
+func (t runResultType) terms() []string {
+	if t.Workload != workloadSearch {
+		return nil
+	}
+	return t.Target.SearchTerms
+}
+
+// PoolWaitPerOp return mean pool wait time per iteration
+func (t runResultType) PoolWaitPerOp() time.Duration {
+	if len(t.Samples) < 1 {
+		return 0
+	}
+	return t.PoolWait / time.Duration(len(t.Samples))
+}
+
+// memStats return the sum of MemStats
+func (t runResultType) memStats() (total memStatsType) {
+	for _, stats := range t.MemStats {
+		total.Iterations += stats.Iterations
+		total.Allocs += stats.Allocs
+		total.AllocBytes += stats.AllocBytes
+		total.GCCycles += stats.GCCycles
+		total.GCPause += stats.GCPause
+	}
+	return
+}
+
+// AllocsPerOp return mean heap allocation count per iteration
+func (t runResultType) AllocsPerOp() uint64 {
+	total := t.memStats()
+	if total.Iterations < 1 {
+		return 0
+	}
+	return total.Allocs / uint64(total.Iterations)
+}
+
+// BytesPerOp return mean heap allocation bytes per iteration
+func (t runResultType) BytesPerOp() uint64 {
+	total := t.memStats()
+	if total.Iterations < 1 {
+		return 0
+	}
+	return total.AllocBytes / uint64(total.Iterations)
+}
+
+// GCCyclesPerOp return mean completed GC cycles per iteration
+func (t runResultType) GCCyclesPerOp() float64 {
+	total := t.memStats()
+	if total.Iterations < 1 {
+		return 0
+	}
+	return float64(total.GCCycles) / float64(total.Iterations)
+}
+
+// GCPausePerOp return mean GC pause time per iteration
+func (t runResultType) GCPausePerOp() time.Duration {
+	total := t.memStats()
+	if total.Iterations < 1 {
+		return 0
+	}
+	return total.GCPause / time.Duration(total.Iterations)
+}
+
+// PhasesPerOp return mean time of each phase per iteration
+func (t runResultType) PhasesPerOp() forumdb.Phases {
+	return t.Phases.Div(len(t.Samples))
+}
+
+// ServerStatsPerOp return mean increase of server statement counters per iteration, nil when not available
+func (t runResultType) ServerStatsPerOp() *serverStatsType {
+	if t.ServerStats == nil || len(t.Samples) < 1 {
+		return nil
+	}
+	stats := t.ServerStats.div(float64(len(t.Samples)))
+	return &stats
+}
+
+// memStatsType is heap allocations and GC of the whole process over Iterations iterations
+type memStatsType struct {
+	Iterations int
+	Allocs     uint64
+	AllocBytes uint64
+	// GCCycles and GCPause are completed GC cycles and their total stop-the-world pause
+	GCCycles uint32
+	GCPause  time.Duration
+}
+
+// memStatsRecorderType record heap allocations and GC between start and stop
+type memStatsRecorderType struct {
+	start runtime.MemStats
+	end   runtime.MemStats
+}
+
+func (t *memStatsRecorderType) Start() {
+	runtime.ReadMemStats(&t.start)
+}
+
+// Stop append allocations and GC since Start over iterations to result
+func (t *memStatsRecorderType) Stop(result *runResultType, iterations int) {
+	runtime.ReadMemStats(&t.end)
+	result.MemStats = append(result.MemStats, memStatsType{
+		Iterations: iterations,
+		Allocs:     t.end.Mallocs - t.start.Mallocs,
+		AllocBytes: t.end.TotalAlloc - t.start.TotalAlloc,
+		GCCycles:   t.end.NumGC - t.start.NumGC,
+		GCPause:    time.Duration(t.end.PauseTotalNs - t.start.PauseTotalNs),
+	})
+}
+
+// runStrategy run strategy repeatedly in one transaction on target
+func runStrategy(
+	ctx context.Context,
+	target *targetType,
+	runCase runCaseType,
+	config runConfigType,
+) (result runResultType, err error) {
+	result = runResultType{
+		runCaseType: runCase,
+		Target:      target,
+	}
+	strategy, txOpt := runCase.Strategy, runCase.TxOption
+
+	tx, err := target.beginTx(ctx, strategy, txOpt)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	picker := runCase.picker(target, 1)
+	for n := 0; n < config.Warmup; n++ {
+		if _, err = strategy.Select(ctx, tx, runCase.request(nil, picker)); err != nil {
+			return
+		}
+	}
+
+	serverStats := serverStatsRecorderType{target: target}
+	if err = serverStats.Start(ctx, tx); err != nil {
+		return
+	}
+	profiles := newProfileRecorder(config.ProfileDir, target, runCase, config)
+	defer profiles.Close()
+	if err = profiles.Start(); err != nil {
+		return
+	}
+	memStats := memStatsRecorderType{}
+	start := time.Now()
+	for n := 0; ; n++ {
+		if config.Iterations > 0 {
+			if n >= config.Iterations {
+				break
+			}
+		} else if time.Since(start) >= config.Duration {
+			break
+		}
+
+		phases := forumdb.Phases{}
+		request := runCase.request(&phases, picker)
+		// memory of every iteration excludes bookkeeping between iterations
+		memStats.Start()
+		iterStart := time.Now()
+		_, err = strategy.Select(ctx, tx, request)
+		sample := time.Since(iterStart)
+		memStats.Stop(&result, 1)
+		if err != nil {
+			return
+		}
+		result.Samples = append(result.Samples, sample)
+		result.Phases = result.Phases.Add(phases)
+	}
+	result.Elapsed = time.Since(start)
+	if err = profiles.Stop(&result); err != nil {
+		return
+	}
+	err = serverStats.Stop(ctx, tx, &result)
+	return
+}
+
+// runCase run the case in sequential or load mode,
+// writers of target write from warm-up until the case is done
+func runCase(ctx context.Context, target *targetType, runCase runCaseType, config runConfigType) (result runResultType, err error) {
+	if target.Writers != nil {
+		if err = target.Writers.Start(); err != nil {
+			return
+		}
+		defer func() {
+			writes, errWrite := target.Writers.Stop()
+			if errWrite != nil && err == nil {
+				err = errWrite
+			}
+			result.Writes = &writes
+		}()
+	}
+
+	if runCase.Clients > 0 {
+		return runLoad(ctx, target, runCase, config)
+	}
+	return runStrategy(ctx, target, runCase, config)
+}
+
+// runLoad run strategy with concurrent clients on target,
+// every iteration takes a connection from pool and runs in its own transaction,
+// Iterations of config is counted per client
+func runLoad(
+	ctx context.Context,
+	target *targetType,
+	runCase runCaseType,
+	config runConfigType,
+) (result runResultType, err error) {
+	result = runResultType{
+		runCaseType: runCase,
+		Target:      target,
+	}
+	clients := runCase.Clients
+	// pool wait is read from the pool of the strategy driver
+	db, err := target.strategyDB(runCase.Strategy)
+	if err != nil {
+		return
+	}
+
+	loadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eg, egCtx := errgroup.WithContext(loadCtx)
+	warmed := sync.WaitGroup{}
+	warmed.Add(clients)
+	start := make(chan struct{})
+	deadline := time.Time{}
+	samples := make([][]time.Duration, clients)
+	phases := make([]forumdb.Phases, clients)
+
+	for c := 0; c < clients; c++ {
+		c := c
+		eg.Go(func() error {
+			picker := runCase.picker(target, int64(c+1))
+			for n := 0; n < config.Warmup; n++ {
+				if err := runLoadIteration(egCtx, target, runCase, nil, picker); err != nil {
+					warmed.Done()
+					return err
+				}
+			}
+			warmed.Done()
+
+			select {
+			case <-egCtx.Done():
+				return nil
+			case <-start:
+			}
+
+			for n := 0; ; n++ {
+				if config.Iterations > 0 {
+					if n >= config.Iterations {
+						return nil
+					}
+				} else if !time.Now().Before(deadline) {
+					return nil
+				}
+
+				iterPhases := forumdb.Phases{}
+				iterStart := time.Now()
+				if err := runLoadIteration(egCtx, target, runCase, &iterPhases, picker); err != nil {
+					return err
+				}
+				samples[c] = append(samples[c], time.Since(iterStart))
+				phases[c] = phases[c].Add(iterPhases)
+			}
+		})
+	}
+
+	warmed.Wait()
+	// clients are waiting for start, so the pool has a free connection
+	serverStats := serverStatsRecorderType{target: target}
+	if err = serverStats.Start(ctx, target.DB); err != nil {
+		cancel()
+		eg.Wait()
+		return
+	}
+	profiles := newProfileRecorder(config.ProfileDir, target, runCase, config)
+	defer profiles.Close()
+	if err = profiles.Start(); err != nil {
+		cancel()
+		eg.Wait()
+		return
+	}
+	memStats := memStatsRecorderType{}
+	memStats.Start()
+	statsStart := db.Stats()
+	begin := time.Now()
+	deadline = begin.Add(config.Duration)
+	close(start)
+
+	if err = eg.Wait(); err != nil {
+		return
+	}
+	result.Elapsed = time.Since(begin)
+	iterations := 0
+	for _, clientSamples := range samples {
+		iterations += len(clientSamples)
+	}
+	memStats.Stop(&result, iterations)
+	if err = profiles.Stop(&result); err != nil {
+		return
+	}
+	result.PoolWait = db.Stats().WaitDuration - statsStart.WaitDuration
+	if err = serverStats.Stop(ctx, target.DB, &result); err != nil {
+		return
+	}
+	for c, clientSamples := range samples {
+		result.Samples = append(result.Samples, clientSamples...)
+		result.Phases = result.Phases.Add(phases[c])
+	}
+	return
+}
+
+func runLoadIteration(ctx context.Context, target *targetType, runCase runCaseType, phases *forumdb.Phases, picker *forumPickerType) (err error) {
+	tx, err := target.beginTx(ctx, runCase.Strategy, runCase.TxOption)
+	if err != nil {
+		return
+	}
+	if _, err = runCase.Strategy.Select(ctx, tx, runCase.request(phases, picker)); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// searchTermCount is the count of terms drawn for the search workload when no term is given
+const searchTermCount = 100
+
+// searchTermSeed seed the term draw, so every run searches the same terms
+const searchTermSeed = 1
+
+// searchVocabulary are words of the golorem word list seed data is generated from,
+// 4 to 11 letters long, shorter words are below the default MySQL FULLTEXT min token size
+var searchVocabulary = strings.Fields(`
+abditis abundabimus adamavi admiratio adsit aegrotantes affectionum agito alibi aliquantum altius
+ambiendum amore animalibus aperit aranea asperum audeo auditur auribus beatum bone caelum cantu
+carentes castissime cepit ceteri claudicans cogitetur colligantur commemoro concurrunt confitetur
+conscientia consuevit continebat copiarum corporis creatorem cuiuscemodi curare david defrito
+demerguntur desiderem detruncata dicere dicuntur diiudicas discernens disputando diversitate
+doctrinis dominum ducere ebrietate elian eosdem erro esurio exarsi experiamur exterius facie facti
+falsa ferre filiorum flete formaeque freni fugasti gaudebit generalis graece gustandi haberent
+haurimus honoris iacitur illac imagine imperfecta incognitam indueris infligi iniqua inmunditiam
+inruebam intellegunt interius intonas inveniebam invoco ipsum item iugo laetatus lata laudari lene
+licet locutus lucis magisque malitia manifesta mecum melodias memoriam meruit minuit misera modicum
+momentum moveat mundatior narium nemo nimia nolunt noscendum nova numquam oblitus occurrant odium
+olent omnipotens opus paratus patriam pecco percurro periculosa perturbor placeant pluris posita
+possunt potui praeparat praeteritae priusquam proferuntur proruunt pulsatori quaere quaesivit quanto
+quicumque quomodo ratio recondi reddatur refugio remotum reptilia respice retibus ruga saepe sancte
+satago scirent secura sensu separavit servis silente sint solet sonis spatiatus splenduisti suavis
+succurrat superbiae tacite tantarum temptatione teneo tetigi toleramus transactam tribuis turibulis
+umquam utendi valent vanus veluti verbo viam videns vindicandi vituperari vocant volui
+`)
+
+// termsLabel return count and checksum of terms, short enough for labels and file names
+func termsLabel(terms []string) string {
+	return fmt.Sprintf("%d:%08x", len(terms), crc32.ChecksumIEEE([]byte(strings.Join(terms, " "))))
+}
+
+// drawSearchTerms return at most count distinct words of searchVocabulary drawn by searchTermSeed, sorted
+func drawSearchTerms(count int) (terms []string) {
+	random := rand.New(rand.NewSource(searchTermSeed))
+	for _, i := range random.Perm(len(searchVocabulary)) {
+		if len(terms) >= count {
+			break
+		}
+		terms = append(terms, searchVocabulary[i])
+	}
+	sort.Strings(terms)
+	return
+}
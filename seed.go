@@ -2,120 +2,79 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
+	"errors"
+	"flag"
 	"log"
-	"os"
 
 	"github.com/drhodes/golorem"
-	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/sync/errgroup"
 	pb "gopkg.in/cheggaaa/pb.v1"
 
-	_ "github.com/lib/pq"
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
 )
 
-func main() {
-	ctx := context.Background()
-
-	dbMySQL, err := newMySQLConnection(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer func() {
-		if err = dbMySQL.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	dbPGSQL, err := newPGSQLConnection()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer func() {
-		if err = dbPGSQL.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	err = insertData(ctx, dbMySQL, dbPGSQL, 100, 1000, 10)
-	trace(err)
-}
-
-func trace(err error) {
-	if err != nil {
-		log.Println(err)
-	}
-}
-
-func newMySQLConnection(ctx context.Context) (db *sqlx.DB, err error) {
-	rootCertPool := x509.NewCertPool()
-	pem, err := ioutil.ReadFile("server-ca.pem")
-	if err != nil {
-		return
-	}
-
-	rootCertPool.AppendCertsFromPEM(pem)
-
-	clientCert := make([]tls.Certificate, 0, 1)
-	certs, err := tls.LoadX509KeyPair("client-cert.pem", "client-key.pem")
-	if err != nil {
-		return
-	}
-
-	clientCert = append(clientCert, certs)
-	mysql.RegisterTLSConfig("custom", &tls.Config{
-		RootCAs:            rootCertPool,
-		Certificates:       clientCert,
-		InsecureSkipVerify: true,
-	})
-
-	if db, err = sqlx.Open("mysql", os.Getenv("MYSQL_URL")); err != nil {
-		return
-	}
-
-	if err = db.Ping(); err != nil {
+func runSeed(ctx context.Context, args []string) (err error) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	forumCount := flags.Int("forums", 100, "forum count")
+	threadCount := flags.Int("threads", 1000, "thread count per forum")
+	postCount := flags.Int("posts", 10, "post count per thread")
+	if err = flags.Parse(args); err != nil {
 		return
 	}
 
-	db.SetMaxOpenConns(100)
-
-	if _, err = db.ExecContext(ctx, `SET SESSION group_concat_max_len = 100000000;`); err != nil {
-		return
+	targets := loadAllTargets()
+	if len(targets) < 1 {
+		return errors.New("no target defined, set MYSQL_URL or PGSQL_URL")
 	}
 
-	return
-}
-
-func newPGSQLConnection() (db *sqlx.DB, err error) {
-	if db, err = sqlx.Open("postgres", os.Getenv("PGSQL_URL")); err != nil {
-		return
-	}
-
-	if err = db.Ping(); err != nil {
-		return
+	dbs := make([]*sqlx.DB, 0, len(targets))
+	for _, target := range targets {
+		if err = target.Open(ctx); err != nil {
+			return
+		}
+		defer func(target *targetType) {
+			if errClose := target.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+		}(target)
+		log.Printf("seed target %s\n", target.Label())
+		// counter columns added to existing rows are recounted, inserts then keep them up to date
+		created, err := forumdb.CreateCounters(ctx, target.DB, target.Dialect, forumdb.Counters)
+		if err != nil {
+			return err
+		}
+		if err = forumdb.RefreshCounters(ctx, target.DB, created); err != nil {
+			return err
+		}
+		dbs = append(dbs, target.DB)
 	}
 
-	db.SetMaxOpenConns(80)
-	return
+	return insertData(ctx, dbs, *forumCount, *threadCount, *postCount)
 }
 
 func insertData(
 	ctx context.Context,
-	txMySQL *sqlx.DB,
-	txPGSQL *sqlx.DB,
+	dbs []*sqlx.DB,
 	forumCount int,
 	threadCountPerForum int,
 	postCountPerThread int,
 ) (err error) {
 	total := int64(forumCount + forumCount*threadCountPerForum + forumCount*threadCountPerForum*postCountPerThread)
-	barMySQL := pb.New64(total).Start()
-	defer barMySQL.Finish()
-	barPGSQL := pb.New64(total).Start()
-	defer barPGSQL.Finish()
+	bars := make([]*pb.ProgressBar, 0, len(dbs))
+	for range dbs {
+		bars = append(bars, pb.New64(total))
+	}
+	pool, err := pb.StartPool(bars...)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if errStop := pool.Stop(); errStop != nil && err == nil {
+			err = errStop
+		}
+	}()
 
 	type chanType struct {
 		ID    string
@@ -187,110 +146,85 @@ func insertData(
 		Thread chanType
 		Post   chanType
 	}
-	insertMySQLChan := make(chan insertType, 1000)
-	insertPGSQLChan := make(chan insertType, 1000)
+	insertChans := make([]chan insertType, 0, len(dbs))
+	for range dbs {
+		insertChans = append(insertChans, make(chan insertType, 1000))
+	}
+	send := func(data insertType) {
+		for _, insertChan := range insertChans {
+			insertChan <- data
+		}
+	}
 
 	egWorker, ctxWorker := errgroup.WithContext(ctx)
 
-	egWorker.Go(func() error {
-		for {
-			select {
-			case <-ctxWorker.Done():
-				return nil
-			case data, ok := <-insertMySQLChan:
-				if !ok {
+	for i, db := range dbs {
+		db := db
+		bar := bars[i]
+		insertChan := insertChans[i]
+		egWorker.Go(func() error {
+			for {
+				select {
+				case <-ctxWorker.Done():
 					return nil
-				}
-				barMySQL.Increment()
-				switch data.Type {
-				case 1:
-					if err := insertForum(ctx, txMySQL, data.Forum.ID, data.Forum.Name, data.Forum.Lorem); err != nil {
-						return err
-					}
-				case 2:
-					if err := insertThread(ctx, txMySQL, data.Forum.ID, data.Thread.ID, data.Thread.Name, data.Thread.Lorem); err != nil {
-						return err
+				case data, ok := <-insertChan:
+					if !ok {
+						return nil
 					}
-				case 3:
-					if err := insertPost(ctx, txMySQL, data.Thread.ID, data.Post.ID, data.Post.Name, data.Post.Lorem); err != nil {
-						return err
+					bar.Increment()
+					switch data.Type {
+					case 1:
+						if err := insertForum(ctx, db, data.Forum.ID, data.Forum.Name, data.Forum.Lorem); err != nil {
+							return err
+						}
+					case 2:
+						if err := inTx(ctx, db, func(tx *sqlx.Tx) error {
+							return insertThread(ctx, tx, data.Forum.ID, data.Thread.ID, data.Thread.Name, data.Thread.Lorem)
+						}); err != nil {
+							return err
+						}
+					case 3:
+						if err := inTx(ctx, db, func(tx *sqlx.Tx) error {
+							return insertPost(ctx, tx, data.Thread.ID, data.Post.ID, data.Post.Name, data.Post.Lorem)
+						}); err != nil {
+							return err
+						}
 					}
 				}
 			}
-		}
-	})
-	egWorker.Go(func() error {
-		for {
-			select {
-			case <-ctxWorker.Done():
-				return nil
-			case data, ok := <-insertPGSQLChan:
-				if !ok {
-					return nil
-				}
-				barPGSQL.Increment()
-				switch data.Type {
-				case 1:
-					if err := insertForum(ctx, txPGSQL, data.Forum.ID, data.Forum.Name, data.Forum.Lorem); err != nil {
-						return err
-					}
-				case 2:
-					if err := insertThread(ctx, txPGSQL, data.Forum.ID, data.Thread.ID, data.Thread.Name, data.Thread.Lorem); err != nil {
-						return err
-					}
-				case 3:
-					if err := insertPost(ctx, txPGSQL, data.Thread.ID, data.Post.ID, data.Post.Name, data.Post.Lorem); err != nil {
-						return err
-					}
-				}
-			}
-		}
-	})
+		})
+	}
 
 	for fc := 0; fc < forumCount; fc++ {
 		forumItem := <-forumChan
-		insertMySQLChan <- insertType{
-			Type:  1,
-			Forum: forumItem,
-		}
-		insertPGSQLChan <- insertType{
+		send(insertType{
 			Type:  1,
 			Forum: forumItem,
-		}
+		})
 
 		for tc := 0; tc < threadCountPerForum; tc++ {
 			threadItem := <-threadChan
-			insertMySQLChan <- insertType{
+			send(insertType{
 				Type:   2,
 				Forum:  forumItem,
 				Thread: threadItem,
-			}
-			insertPGSQLChan <- insertType{
-				Type:   2,
-				Forum:  forumItem,
-				Thread: threadItem,
-			}
+			})
 
 			for pc := 0; pc < postCountPerThread; pc++ {
 				postItem := <-postChan
-				insertMySQLChan <- insertType{
-					Type:   3,
-					Forum:  forumItem,
-					Thread: threadItem,
-					Post:   postItem,
-				}
-				insertPGSQLChan <- insertType{
+				send(insertType{
 					Type:   3,
 					Forum:  forumItem,
 					Thread: threadItem,
 					Post:   postItem,
-				}
+				})
 			}
 		}
 	}
 
-	close(insertMySQLChan)
-	close(insertPGSQLChan)
+	for _, insertChan := range insertChans {
+		close(insertChan)
+	}
 	if err = egWorker.Wait(); err != nil {
 		return
 	}
@@ -305,12 +239,12 @@ func insertData(
 
 func insertForum(
 	ctx context.Context,
-	tx *sqlx.DB,
+	tx sqlx.ExtContext,
 	forumID string,
 	forumName string,
 	forumLorem string,
 ) (err error) {
-	_, err = tx.NamedExecContext(ctx, `
+	_, err = sqlx.NamedExecContext(ctx, tx, `
 INSERT INTO forums
 	(forumID, name, lorem)
 VALUES
@@ -320,21 +254,19 @@ VALUES
 		"name":    forumName,
 		"lorem":   forumLorem,
 	})
-	if err != nil {
-		panic(err)
-	}
 	return
 }
 
+// insertThread insert thread and add it to the thread counter of forum, tx should be a transaction
 func insertThread(
 	ctx context.Context,
-	tx *sqlx.DB,
+	tx sqlx.ExtContext,
 	forumID string,
 	threadID string,
 	threadName string,
 	threadLorem string,
 ) (err error) {
-	_, err = tx.NamedExecContext(ctx, `
+	if _, err = sqlx.NamedExecContext(ctx, tx, `
 INSERT INTO threads
 	(forumID, threadID, name, lorem)
 VALUES
@@ -344,22 +276,22 @@ VALUES
 		"threadID": threadID,
 		"name":     threadName,
 		"lorem":    threadLorem,
-	})
-	if err != nil {
-		panic(err)
+	}); err != nil {
+		return
 	}
-	return
+	return forumdb.ThreadCounter.Add(ctx, tx, forumID, 1)
 }
 
+// insertPost insert post and add it to the post counter of thread, tx should be a transaction
 func insertPost(
 	ctx context.Context,
-	tx *sqlx.DB,
+	tx sqlx.ExtContext,
 	threadID string,
 	postID string,
 	postName string,
 	postLorem string,
 ) (err error) {
-	_, err = tx.NamedExecContext(ctx, `
+	if _, err = sqlx.NamedExecContext(ctx, tx, `
 INSERT INTO posts
 	(threadID, postID, name, lorem)
 VALUES
@@ -369,9 +301,37 @@ VALUES
 		"postID":   postID,
 		"name":     postName,
 		"lorem":    postLorem,
-	})
+	}); err != nil {
+		return
+	}
+	return forumdb.PostCounter.Add(ctx, tx, threadID, 1)
+}
+
+// deleteThread delete thread with its posts and remove it from the thread counter of forum, tx should be a transaction
+func deleteThread(ctx context.Context, tx sqlx.ExtContext, forumID string, threadID string) (err error) {
+	if _, err = tx.ExecContext(ctx, tx.Rebind(`DELETE FROM threads WHERE threadID = ?;`), threadID); err != nil {
+		return
+	}
+	return forumdb.ThreadCounter.Add(ctx, tx, forumID, -1)
+}
+
+// deletePost delete post and remove it from the post counter of thread, tx should be a transaction
+func deletePost(ctx context.Context, tx sqlx.ExtContext, threadID string, postID string) (err error) {
+	if _, err = tx.ExecContext(ctx, tx.Rebind(`DELETE FROM posts WHERE postID = ?;`), postID); err != nil {
+		return
+	}
+	return forumdb.PostCounter.Add(ctx, tx, threadID, -1)
+}
+
+// inTx run fn in a transaction of db, commit when fn succeeds
+func inTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
-		panic(err)
+		return
 	}
-	return
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
 }
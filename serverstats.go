@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// serverStatsType is the sum of server statement counters of SELECT statements in the target database,
+// counters not provided by the dialect stay zero
+type serverStatsType struct {
+	Calls float64 `json:"calls" db:"calls"`
+	// Rows is rows returned on PostgreSQL, rows sent on MySQL
+	Rows float64 `json:"rows" db:"rows_count"`
+
+	// PostgreSQL pg_stat_statements
+	SharedBlksHit   float64 `json:"sharedBlksHit" db:"shared_blks_hit"`
+	SharedBlksRead  float64 `json:"sharedBlksRead" db:"shared_blks_read"`
+	TempBlksRead    float64 `json:"tempBlksRead" db:"temp_blks_read"`
+	TempBlksWritten float64 `json:"tempBlksWritten" db:"temp_blks_written"`
+
+	// MySQL performance_schema.events_statements_summary_by_digest
+	RowsExamined    float64 `json:"rowsExamined" db:"rows_examined"`
+	TmpTables       float64 `json:"tmpTables" db:"tmp_tables"`
+	TmpDiskTables   float64 `json:"tmpDiskTables" db:"tmp_disk_tables"`
+	SortMergePasses float64 `json:"sortMergePasses" db:"sort_merge_passes"`
+}
+
+// sub return counters increased since start
+func (t serverStatsType) sub(start serverStatsType) serverStatsType {
+	return serverStatsType{
+		Calls:           t.Calls - start.Calls,
+		Rows:            t.Rows - start.Rows,
+		SharedBlksHit:   t.SharedBlksHit - start.SharedBlksHit,
+		SharedBlksRead:  t.SharedBlksRead - start.SharedBlksRead,
+		TempBlksRead:    t.TempBlksRead - start.TempBlksRead,
+		TempBlksWritten: t.TempBlksWritten - start.TempBlksWritten,
+		RowsExamined:    t.RowsExamined - start.RowsExamined,
+		TmpTables:       t.TmpTables - start.TmpTables,
+		TmpDiskTables:   t.TmpDiskTables - start.TmpDiskTables,
+		SortMergePasses: t.SortMergePasses - start.SortMergePasses,
+	}
+}
+
+// div return counters divided by n
+func (t serverStatsType) div(n float64) serverStatsType {
+	return serverStatsType{
+		Calls:           t.Calls / n,
+		Rows:            t.Rows / n,
+		SharedBlksHit:   t.SharedBlksHit / n,
+		SharedBlksRead:  t.SharedBlksRead / n,
+		TempBlksRead:    t.TempBlksRead / n,
+		TempBlksWritten: t.TempBlksWritten / n,
+		RowsExamined:    t.RowsExamined / n,
+		TmpTables:       t.TmpTables / n,
+		TmpDiskTables:   t.TmpDiskTables / n,
+		SortMergePasses: t.SortMergePasses / n,
+	}
+}
+
+// String return counters of dialect
+func (t serverStatsType) String(dialect forumdb.Dialect) string {
+	switch dialect {
+	case forumdb.MySQL:
+		return fmt.Sprintf("calls=%.1f rows_sent=%.1f rows_examined=%.1f tmp_tables=%.1f tmp_disk_tables=%.1f sort_merge_passes=%.1f",
+			t.Calls, t.Rows, t.RowsExamined, t.TmpTables, t.TmpDiskTables, t.SortMergePasses)
+	case forumdb.PGSQL:
+		return fmt.Sprintf("calls=%.1f rows=%.1f shared_hit=%.1f shared_read=%.1f temp_read=%.1f temp_written=%.1f",
+			t.Calls, t.Rows, t.SharedBlksHit, t.SharedBlksRead, t.TempBlksRead, t.TempBlksWritten)
+	}
+	return ""
+}
+
+// readServerStats snapshot statement counters of target database,
+// PostgreSQL needs the pg_stat_statements extension, MySQL needs performance_schema enabled,
+// statements of other sessions in the same database are counted as well
+func readServerStats(ctx context.Context, queryer sqlx.QueryerContext, dialect forumdb.Dialect) (stats serverStatsType, err error) {
+	switch dialect {
+	case forumdb.MySQL:
+		err = sqlx.GetContext(ctx, queryer, &stats, selectMySQLServerStatsQuery)
+	case forumdb.PGSQL:
+		err = sqlx.GetContext(ctx, queryer, &stats, selectPGSQLServerStatsQuery)
+	default:
+		err = fmt.Errorf("unknown dialect %q", dialect)
+	}
+	return
+}
+
+const selectMySQLServerStatsQuery = `
+SELECT COALESCE(SUM(COUNT_STAR), 0) AS calls,
+	COALESCE(SUM(SUM_ROWS_SENT), 0) AS rows_count,
+	COALESCE(SUM(SUM_ROWS_EXAMINED), 0) AS rows_examined,
+	COALESCE(SUM(SUM_CREATED_TMP_TABLES), 0) AS tmp_tables,
+	COALESCE(SUM(SUM_CREATED_TMP_DISK_TABLES), 0) AS tmp_disk_tables,
+	COALESCE(SUM(SUM_SORT_MERGE_PASSES), 0) AS sort_merge_passes
+FROM performance_schema.events_statements_summary_by_digest
+WHERE SCHEMA_NAME = DATABASE()
+AND DIGEST_TEXT LIKE 'SELECT%'
+AND DIGEST_TEXT NOT LIKE '%events_statements_summary_by_digest%'
+;`
+
+const selectPGSQLServerStatsQuery = `
+SELECT COALESCE(SUM(calls), 0)::float8 AS calls,
+	COALESCE(SUM(rows), 0)::float8 AS rows_count,
+	COALESCE(SUM(shared_blks_hit), 0)::float8 AS shared_blks_hit,
+	COALESCE(SUM(shared_blks_read), 0)::float8 AS shared_blks_read,
+	COALESCE(SUM(temp_blks_read), 0)::float8 AS temp_blks_read,
+	COALESCE(SUM(temp_blks_written), 0)::float8 AS temp_blks_written
+FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+AND query ~* '^\s*SELECT'
+AND query NOT LIKE '%pg_stat_statements%'
+;`
+
+// serverStatsRecorderType record server statement counters between start and stop,
+// it does nothing when target can't provide server stats
+type serverStatsRecorderType struct {
+	target *targetType
+	start  serverStatsType
+}
+
+func (t *serverStatsRecorderType) Start(ctx context.Context, queryer sqlx.QueryerContext) (err error) {
+	if !t.target.ServerStats {
+		return
+	}
+	t.start, err = readServerStats(ctx, queryer, t.target.Dialect)
+	return
+}
+
+func (t *serverStatsRecorderType) Stop(ctx context.Context, queryer sqlx.QueryerContext, result *runResultType) (err error) {
+	if !t.target.ServerStats {
+		return
+	}
+	end, err := readServerStats(ctx, queryer, t.target.Dialect)
+	if err != nil {
+		return
+	}
+	stats := end.sub(t.start)
+	result.ServerStats = &stats
+	return
+}
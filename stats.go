@@ -0,0 +1,164 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// latencyType is the latency distribution of samples
+type latencyType struct {
+	Min  time.Duration `json:"minNs"`
+	Mean time.Duration `json:"meanNs"`
+	P50  time.Duration `json:"p50Ns"`
+	P90  time.Duration `json:"p90Ns"`
+	P99  time.Duration `json:"p99Ns"`
+	Max  time.Duration `json:"maxNs"`
+}
+
+func newLatency(samples []time.Duration) (latency latencyType) {
+	if len(samples) < 1 {
+		return
+	}
+	sorted := append([]time.Duration{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	total := time.Duration(0)
+	for _, sample := range sorted {
+		total += sample
+	}
+
+	return latencyType{
+		Min:  sorted[0],
+		Mean: total / time.Duration(len(sorted)),
+		P50:  percentile(sorted, 50),
+		P90:  percentile(sorted, 90),
+		P99:  percentile(sorted, 99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// percentile return nearest-rank p-th percentile of sorted samples,
+// the smallest sample not less than p percent of samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) < 1 {
+		return 0
+	}
+	// multiply before divide, p/100 is not exact and 0.07*100 would ceil to 8
+	rank := int(math.Ceil(p*float64(len(sorted))/100)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// median return median of values, values are not modified
+func median(values []float64) float64 {
+	if len(values) < 1 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// mannWhitneyU return the two-sided p-value of Mann-Whitney U test of samples a and b,
+// using normal approximation with tie and continuity correction
+func mannWhitneyU(a []float64, b []float64) float64 {
+	n1 := float64(len(a))
+	n2 := float64(len(b))
+	if n1 < 1 || n2 < 1 {
+		return 1
+	}
+
+	type valueType struct {
+		value float64
+		fromA bool
+	}
+	values := make([]valueType, 0, len(a)+len(b))
+	for _, v := range a {
+		values = append(values, valueType{value: v, fromA: true})
+	}
+	for _, v := range b {
+		values = append(values, valueType{value: v})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].value < values[j].value
+	})
+
+	// rank with average of ties
+	rankA := 0.0
+	tieTerm := 0.0
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].value == values[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u := rankA - n1*(n1+1)/2
+	mu := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// bootstrapMedianDelta return the confidence interval of relative median change from old to new,
+// e.g. 0.1 is 10% larger, by resampling both samples
+func bootstrapMedianDelta(oldValues []float64, newValues []float64, resamples int, confidence float64, rnd *rand.Rand) (lo float64, hi float64) {
+	if len(oldValues) < 1 || len(newValues) < 1 {
+		return
+	}
+	resample := func(values []float64, buffer []float64) float64 {
+		for i := range buffer {
+			buffer[i] = values[rnd.Intn(len(values))]
+		}
+		return median(buffer)
+	}
+
+	oldBuffer := make([]float64, len(oldValues))
+	newBuffer := make([]float64, len(newValues))
+	deltas := make([]float64, 0, resamples)
+	for i := 0; i < resamples; i++ {
+		oldMedian := resample(oldValues, oldBuffer)
+		if oldMedian == 0 {
+			continue
+		}
+		deltas = append(deltas, resample(newValues, newBuffer)/oldMedian-1)
+	}
+	if len(deltas) < 1 {
+		return
+	}
+	sort.Float64s(deltas)
+
+	tail := (1 - confidence) / 2
+	lo = deltas[int(tail*float64(len(deltas)-1))]
+	hi = deltas[int((1-tail)*float64(len(deltas)-1)+0.5)]
+	return
+}
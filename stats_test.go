@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newLatency(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	require.Equal(latencyType{}, newLatency(nil))
+
+	samples := []time.Duration{}
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	latency := newLatency(samples)
+	assert.Equal(1*time.Millisecond, latency.Min)
+	assert.Equal(50500*time.Microsecond, latency.Mean)
+	assert.Equal(50*time.Millisecond, latency.P50)
+	assert.Equal(90*time.Millisecond, latency.P90)
+	assert.Equal(99*time.Millisecond, latency.P99)
+	assert.Equal(100*time.Millisecond, latency.Max)
+	assert.Equal(100*time.Millisecond, samples[0], "samples should not be sorted in place")
+
+	latency = newLatency([]time.Duration{3 * time.Millisecond})
+	assert.Equal(3*time.Millisecond, latency.P50)
+	assert.Equal(3*time.Millisecond, latency.P99)
+}
+
+func Test_percentile(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	assert.Equal(time.Duration(0), percentile(nil, 99))
+
+	sorted := []time.Duration{}
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(10*time.Millisecond, percentile(sorted, 99), "p99 of 10 samples should be the max")
+	assert.Equal(9*time.Millisecond, percentile(sorted, 90))
+	assert.Equal(5*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(1*time.Millisecond, percentile(sorted, 7))
+	assert.Equal(1*time.Millisecond, percentile(sorted, 0))
+	assert.Equal(10*time.Millisecond, percentile(sorted, 100))
+
+	sorted = []time.Duration{1 * time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}
+	assert.Equal(2*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(3*time.Millisecond, percentile(sorted, 90))
+}
+
+func Test_mannWhitneyU(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	p := mannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+	assert.InDelta(0.0122, p, 0.0001)
+
+	p = mannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{1, 2, 3, 4, 5})
+	assert.InDelta(1, p, 0.0001)
+
+	p = mannWhitneyU([]float64{3, 3, 3}, []float64{3, 3, 3})
+	assert.Equal(1.0, p)
+
+	p = mannWhitneyU(nil, []float64{1})
+	assert.Equal(1.0, p)
+}
+
+func Test_bootstrapMedianDelta(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	rnd := rand.New(rand.NewSource(1))
+	oldValues := []float64{}
+	newValues := []float64{}
+	for i := 0; i < 100; i++ {
+		oldValues = append(oldValues, float64(100+i%10))
+		newValues = append(newValues, float64(120+i%10))
+	}
+
+	lo, hi := bootstrapMedianDelta(oldValues, newValues, 1000, 0.95, rnd)
+	assert.True(lo <= hi)
+	assert.True(lo > 0.15, "lo %v", lo)
+	assert.True(hi < 0.25, "hi %v", hi)
+	assert.InDelta(1.5, median([]float64{2, 1}), 0)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// heapSamplerType track the peak of heap object bytes above the heap after GC,
+// reading runtime metrics doesn't stop the world
+type heapSamplerType struct {
+	mutex    sync.Mutex
+	samples  []metrics.Sample
+	baseline uint64
+	peak     uint64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// heapSampleInterval is the sampling interval between Start and Stop
+const heapSampleInterval = 100 * time.Microsecond
+
+func newHeapSampler() *heapSamplerType {
+	return &heapSamplerType{
+		samples: []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}},
+	}
+}
+
+func (t *heapSamplerType) read() uint64 {
+	metrics.Read(t.samples)
+	return t.samples[0].Value.Uint64()
+}
+
+// Reset collect garbage and use current heap as baseline, peak is kept
+func (t *heapSamplerType) Reset() {
+	runtime.GC()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.baseline = t.read()
+}
+
+// Sample update peak with current heap
+func (t *heapSamplerType) Sample() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if current := t.read(); current > t.baseline && current-t.baseline > t.peak {
+		t.peak = current - t.baseline
+	}
+}
+
+// Start sample every heapSampleInterval in a goroutine until Stop,
+// so the peak while a call is fetching and decoding is seen
+func (t *heapSamplerType) Start() {
+	t.stop, t.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.Sample()
+			}
+		}
+	}()
+}
+
+// Stop stop sampling goroutine and sample once more
+func (t *heapSamplerType) Stop() {
+	close(t.stop)
+	<-t.done
+	t.Sample()
+}
+
+func Test_heapSampler(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	heap := newHeapSampler()
+	heap.Reset()
+	heap.Start()
+	buffer := make([]byte, 64<<20)
+	for i := range buffer {
+		buffer[i] = 1
+	}
+	time.Sleep(20 * heapSampleInterval)
+	runtime.KeepAlive(buffer)
+	buffer = nil
+	// the buffer is gone before Stop, only samples of the goroutine see it
+	runtime.GC()
+	heap.Stop()
+	assert.True(heap.peak > 32<<20, "peak %d should include the freed buffer", heap.peak)
+}
+
+// benchmarkStream run every strategy of dialect in buffered and streaming mode
+// and report peak heap of one iteration and time to the first complete forum
+func benchmarkStream(b *testing.B, dialect forumdb.Dialect) {
+	require := require.New(b)
+	require.NotNil(require)
+
+	ctx := context.Background()
+
+	targets := loadTargets(dialect)
+	require.NotEmpty(targets, "no %s target defined", dialect)
+
+	for _, target := range targets {
+		require.NoError(target.Open(ctx))
+		for _, strategy := range forumdb.DialectStrategies(target.Dialect) {
+			for _, streaming := range []bool{false, true} {
+				mode := "buffered"
+				if streaming {
+					mode = "streaming"
+				}
+				b.Run(target.Label()+"/"+strategy.Name+"/"+mode, func(b *testing.B) {
+					benchmarkStreamStrategy(b, ctx, target, strategy, streaming)
+				})
+			}
+		}
+		require.NoError(target.Close())
+	}
+}
+
+func benchmarkStreamStrategy(b *testing.B, ctx context.Context, target *targetType, strategy forumdb.Strategy, streaming bool) {
+	require := require.New(b)
+	require.NotNil(require)
+
+	tx, err := target.beginTx(ctx, strategy, defaultTxOption)
+	require.NoError(err)
+	defer tx.Rollback()
+
+	heap := newHeapSampler()
+	firstForum := time.Duration(0)
+	// firstForums is the count of iterations returning any forum
+	firstForums := 0
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		heap.Reset()
+		heap.Start()
+		b.StartTimer()
+
+		start := time.Now()
+		first := true
+		request := forumdb.Request{Limit: forumdb.DefaultLimit}
+		if streaming {
+			request.OnForum = func(forum forumdb.Forum) error {
+				if first {
+					firstForum += time.Since(start)
+					firstForums++
+					first = false
+				}
+				return nil
+			}
+		}
+		result, err := strategy.Select(ctx, tx, request)
+		require.NoError(err)
+		if !streaming && len(result) > 0 {
+			// buffered forums are available only after Select returns
+			firstForum += time.Since(start)
+			firstForums++
+		}
+
+		b.StopTimer()
+		heap.Stop()
+		runtime.KeepAlive(result)
+		b.StartTimer()
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(heap.peak), "peak-heap-B")
+	if firstForums > 0 {
+		b.ReportMetric(float64(firstForum)/float64(firstForums), "first-forum-ns/op")
+	}
+}
+
+func BenchmarkMySQLStream(b *testing.B) {
+	benchmarkStream(b, forumdb.MySQL)
+}
+
+func BenchmarkPGSQLStream(b *testing.B) {
+	benchmarkStream(b, forumdb.PGSQL)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+
+	_ "github.com/lib/pq"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// dialectEnvs map dialect to the env name of connection url,
+// both NAME and NAME_<SUFFIX> are accepted to define multiple targets
+var dialectEnvs = map[forumdb.Dialect]string{
+	forumdb.MySQL: "MYSQL_URL",
+	forumdb.PGSQL: "PGSQL_URL",
+}
+
+type targetType struct {
+	Name    string
+	Dialect forumdb.Dialect
+	URL     string
+	DB      *sqlx.DB
+	// PGX is the PostgreSQL db of pgx stdlib driver, for strategies needing native pgx
+	PGX     *sqlx.DB
+	pgxErr  error
+	Version string
+	Shape   dataShapeType
+	// ForumIDs are all forum IDs in forumID order, loaded before running point lookups
+	ForumIDs []string
+	// SearchTerms are terms of the search workload, set before running searches
+	SearchTerms []string
+	// Writers is the write-load process writing target while every case reads, nil without write load
+	Writers *writeProcessType
+	// ServerStats is true when server statement counters are readable
+	ServerStats bool
+	// DriverStats count driver operations when not nil before Open
+	DriverStats *driverStatsType
+	// Network is simulated by a local proxy when not zero before Open
+	Network networkType
+	proxy   *latencyProxyType
+}
+
+// Label return target name with detected server version and simulated network for output
+func (t targetType) Label() string {
+	label := t.Name
+	if t.Version != "" {
+		label += "@" + t.Version
+	}
+	if t.Network != (networkType{}) {
+		label += "/" + t.Network.String()
+	}
+	return label
+}
+
+// loadTargets return targets of dialect defined in env, sorted by name,
+// e.g. MYSQL_URL -> mysql, MYSQL_URL_57 -> mysql_57
+func loadTargets(dialect forumdb.Dialect) (targets []*targetType) {
+	return loadEnvTargets(dialect, dialectEnvs[dialect])
+}
+
+// loadEnvTargets return targets of dialect defined in env envName or envName_<SUFFIX>
+func loadEnvTargets(dialect forumdb.Dialect, envName string) (targets []*targetType) {
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			continue
+		}
+		if kv[0] != envName && !strings.HasPrefix(kv[0], envName+"_") {
+			continue
+		}
+		targets = append(targets, &targetType{
+			Name:    strings.ToLower(strings.Replace(kv[0], "_URL", "", 1)),
+			Dialect: dialect,
+			URL:     kv[1],
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+	return
+}
+
+// loadAllTargets return targets of all dialects
+func loadAllTargets() (targets []*targetType) {
+	targets = append(targets, loadTargets(forumdb.MySQL)...)
+	targets = append(targets, loadTargets(forumdb.PGSQL)...)
+	return
+}
+
+// Open connect to target and detect server version,
+// connect through a latency proxy when Network is set
+func (t *targetType) Open(ctx context.Context) (err error) {
+	connURL := t.URL
+	if t.Network != (networkType{}) {
+		if connURL, err = t.startProxy(); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil {
+				t.Close()
+			}
+		}()
+	}
+
+	switch t.Dialect {
+	case forumdb.MySQL:
+		if t.DB, err = newMySQLConnection(connURL, t.DriverStats); err != nil {
+			return
+		}
+		err = t.DB.GetContext(ctx, &t.Version, `SELECT VERSION();`)
+	case forumdb.PGSQL:
+		if t.DB, err = newPGSQLConnection(connURL, t.DriverStats); err != nil {
+			return
+		}
+		// only strategies of pgx driver fail when connection url is not accepted by pgx
+		if t.PGX, t.pgxErr = openDB(forumdb.DriverPGX, withUTCSession(forumdb.PGSQL, connURL), t.DriverStats); t.pgxErr == nil {
+			// same pool size as the lib/pq db, so pool wait of both drivers compare
+			t.PGX.SetMaxOpenConns(pgsqlMaxOpenConns)
+		}
+		err = t.DB.GetContext(ctx, &t.Version, `SHOW server_version;`)
+	default:
+		return fmt.Errorf("unknown dialect %q of target %q", t.Dialect, t.Name)
+	}
+	if err != nil {
+		return
+	}
+	// PostgreSQL may append distribution info, e.g. "16.1 (Debian 16.1-1.pgdg120+1)"
+	t.Version = strings.SplitN(t.Version, " ", 2)[0]
+	return
+}
+
+// startProxy start latency proxy to target, return connection url through proxy
+func (t *targetType) startProxy() (connURL string, err error) {
+	upstream, _, err := proxyDSN(t.Dialect, t.URL, "")
+	if err != nil {
+		return
+	}
+	if t.proxy, err = startLatencyProxy(upstream, t.Network); err != nil {
+		return
+	}
+	_, connURL, err = proxyDSN(t.Dialect, t.URL, t.proxy.Addr())
+	return
+}
+
+// dbs return all opened dbs of target
+func (t *targetType) dbs() (dbs []*sqlx.DB) {
+	for _, db := range []*sqlx.DB{t.DB, t.PGX} {
+		if db != nil {
+			dbs = append(dbs, db)
+		}
+	}
+	return
+}
+
+// strategyDB return the db of strategy driver
+func (t *targetType) strategyDB(strategy forumdb.Strategy) (*sqlx.DB, error) {
+	if strategy.Driver != forumdb.DriverPGX {
+		return t.DB, nil
+	}
+	if t.PGX == nil {
+		return nil, fmt.Errorf("strategy %s needs pgx driver: %v", strategy.Name, t.pgxErr)
+	}
+	return t.PGX, nil
+}
+
+// beginTx begin transaction on the db of strategy driver
+func (t *targetType) beginTx(ctx context.Context, strategy forumdb.Strategy, opt txOptionType) (tx txType, err error) {
+	db, err := t.strategyDB(strategy)
+	if err != nil {
+		return
+	}
+	if strategy.Driver != forumdb.DriverPGX {
+		sqlxTx, err := beginTx(ctx, db, opt)
+		if err != nil {
+			return nil, err
+		}
+		return sqlxTx, nil
+	}
+	pgxTx, err := forumdb.BeginPGXTx(ctx, db, opt.TxOptions())
+	if err != nil {
+		return nil, err
+	}
+	return pgxTx, nil
+}
+
+// Close disconnect from target
+func (t *targetType) Close() (err error) {
+	for _, db := range t.dbs() {
+		if errClose := db.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}
+	t.DB, t.PGX, t.pgxErr = nil, nil, nil
+	if t.proxy != nil {
+		if errProxy := t.proxy.Close(); errProxy != nil && err == nil {
+			err = errProxy
+		}
+		t.proxy = nil
+	}
+	return
+}
+
+func newMySQLConnection(connURL string, stats *driverStatsType) (db *sqlx.DB, err error) {
+	if mysqlDSNUsesCustomTLS(connURL) {
+		if err = registerMySQLTLS(); err != nil {
+			return
+		}
+	}
+
+	if db, err = openDB("mysql", withGroupConcatMaxLen(withUTCSession(forumdb.MySQL, connURL)), stats); err != nil {
+		return
+	}
+
+	if err = db.Ping(); err != nil {
+		return
+	}
+
+	db.SetMaxOpenConns(100)
+	return
+}
+
+// mysqlDSNUsesCustomTLS return true when dsn sets tls=custom
+func mysqlDSNUsesCustomTLS(dsn string) bool {
+	parts := strings.SplitN(dsn, "?", 2)
+	if len(parts) < 2 {
+		return false
+	}
+	params, err := url.ParseQuery(parts[1])
+	if err != nil {
+		return false
+	}
+	return params.Get("tls") == "custom"
+}
+
+// registerMySQLTLS register tls config "custom" with the google cloud SQL certificates in working directory
+func registerMySQLTLS() (err error) {
+	rootCertPool := x509.NewCertPool()
+	pem, err := ioutil.ReadFile("server-ca.pem")
+	if err != nil {
+		return
+	}
+
+	rootCertPool.AppendCertsFromPEM(pem)
+
+	clientCert := make([]tls.Certificate, 0, 1)
+	certs, err := tls.LoadX509KeyPair("client-cert.pem", "client-key.pem")
+	if err != nil {
+		return
+	}
+
+	clientCert = append(clientCert, certs)
+	return mysql.RegisterTLSConfig("custom", &tls.Config{
+		RootCAs:            rootCertPool,
+		Certificates:       clientCert,
+		InsecureSkipVerify: true,
+	})
+}
+
+func newPGSQLConnection(connURL string, stats *driverStatsType) (db *sqlx.DB, err error) {
+	if db, err = openDB("postgres", withUTCSession(forumdb.PGSQL, connURL), stats); err != nil {
+		return
+	}
+
+	if err = db.Ping(); err != nil {
+		return
+	}
+
+	db.SetMaxOpenConns(pgsqlMaxOpenConns)
+	return
+}
+
+// pgsqlMaxOpenConns is the pool size of PostgreSQL dbs
+const pgsqlMaxOpenConns = 80
+
+// withUTCSession return connURL setting session time zone to UTC on every connection,
+// so timestamps formatted by the server without offset are UTC, an explicit time zone in connURL is kept
+func withUTCSession(dialect forumdb.Dialect, connURL string) string {
+	switch dialect {
+	case forumdb.MySQL:
+		if strings.Contains(connURL, "time_zone=") {
+			return connURL
+		}
+		return appendDSNParam(connURL, "time_zone="+url.QueryEscape("'+00:00'"))
+	case forumdb.PGSQL:
+		if strings.Contains(strings.ToLower(connURL), "timezone=") {
+			return connURL
+		}
+		if strings.HasPrefix(connURL, "postgres://") || strings.HasPrefix(connURL, "postgresql://") {
+			return appendDSNParam(connURL, "timezone=UTC")
+		}
+		return connURL + " timezone=UTC"
+	}
+	return connURL
+}
+
+// withGroupConcatMaxLen return MySQL connURL raising group_concat_max_len on every connection,
+// so GROUP_CONCAT of the sub-query strategy doesn't truncate the JSON array, an explicit value in connURL is kept
+func withGroupConcatMaxLen(connURL string) string {
+	if strings.Contains(connURL, "group_concat_max_len=") {
+		return connURL
+	}
+	return appendDSNParam(connURL, "group_concat_max_len=100000000")
+}
+
+// appendDSNParam append query param to connURL
+func appendDSNParam(connURL string, param string) string {
+	if strings.Contains(connURL, "?") {
+		return connURL + "&" + param
+	}
+	return connURL + "?" + param
+}
+
+// openDB open connURL with driver, instrumented when stats is not nil
+func openDB(driverName string, connURL string, stats *driverStatsType) (*sqlx.DB, error) {
+	if stats != nil {
+		return openInstrumentedDB(driverName, connURL, stats)
+	}
+	return sqlx.Open(driverName, connURL)
+}
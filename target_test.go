@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_withUTCSession(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	assert.Equal("user:pass@tcp(db:3306)/forum?time_zone=%27%2B00%3A00%27", withUTCSession(forumdb.MySQL, "user:pass@tcp(db:3306)/forum"))
+	assert.Equal("postgresql://user:pass@db/forum?sslmode=require&timezone=UTC", withUTCSession(forumdb.PGSQL, "postgresql://user:pass@db/forum?sslmode=require"))
+	assert.Equal("host=db dbname=forum timezone=UTC", withUTCSession(forumdb.PGSQL, "host=db dbname=forum"))
+}
+
+func Test_withGroupConcatMaxLen(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	assert.Equal("user:pass@tcp(db:3306)/forum?group_concat_max_len=100000000", withGroupConcatMaxLen("user:pass@tcp(db:3306)/forum"))
+	assert.Equal("user:pass@tcp(db:3306)/forum?group_concat_max_len=1024", withGroupConcatMaxLen("user:pass@tcp(db:3306)/forum?group_concat_max_len=1024"))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// isolationNames map option name to sql isolation level,
+// repeatable-read is snapshot isolation on both InnoDB and PostgreSQL, so snapshot is repeatable-read,
+// neither driver accepts sql.LevelSnapshot
+var isolationNames = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read-uncommitted": sql.LevelReadUncommitted,
+	"read-committed":   sql.LevelReadCommitted,
+	"repeatable-read":  sql.LevelRepeatableRead,
+	"snapshot":         sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+// txOptionType is the transaction setting of a benchmark run
+type txOptionType struct {
+	Isolation string
+	ReadOnly  bool
+}
+
+var defaultTxOption = txOptionType{Isolation: "default"}
+
+func (t txOptionType) String() string {
+	if t.ReadOnly {
+		return t.Isolation + "+ro"
+	}
+	return t.Isolation
+}
+
+// TxOptions return options for BeginTxx, nil for server default
+func (t txOptionType) TxOptions() *sql.TxOptions {
+	if t == defaultTxOption {
+		return nil
+	}
+	return &sql.TxOptions{
+		Isolation: isolationNames[t.Isolation],
+		ReadOnly:  t.ReadOnly,
+	}
+}
+
+// parseTxOptions return every combination of comma separated isolation names and read-only flags
+func parseTxOptions(isolations string, readOnlys string) (opts []txOptionType, err error) {
+	if isolations == "" {
+		isolations = defaultTxOption.Isolation
+	}
+	if readOnlys == "" {
+		readOnlys = "false"
+	}
+	for _, isolation := range strings.Split(isolations, ",") {
+		isolation = strings.TrimSpace(isolation)
+		if _, ok := isolationNames[isolation]; !ok {
+			return nil, fmt.Errorf("unknown isolation level %q", isolation)
+		}
+		for _, readOnly := range strings.Split(readOnlys, ",") {
+			ro, err := strconv.ParseBool(strings.TrimSpace(readOnly))
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, txOptionType{
+				Isolation: isolation,
+				ReadOnly:  ro,
+			})
+		}
+	}
+	return
+}
+
+// txType is a transaction passed to strategies
+type txType interface {
+	sqlx.QueryerContext
+	Commit() error
+	Rollback() error
+}
+
+func beginTx(ctx context.Context, db *sqlx.DB, opt txOptionType) (*sqlx.Tx, error) {
+	return db.BeginTxx(ctx, opt.TxOptions())
+}
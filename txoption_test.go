@@ -0,0 +1,38 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseTxOptions(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	opts, err := parseTxOptions("", "")
+	require.NoError(err)
+	require.Equal([]txOptionType{defaultTxOption}, opts)
+	require.Nil(opts[0].TxOptions())
+
+	opts, err = parseTxOptions("read-committed, serializable", "false,true")
+	require.NoError(err)
+	require.Len(opts, 4)
+	assert.Equal("read-committed", opts[0].String())
+	assert.Equal("read-committed+ro", opts[1].String())
+	assert.Equal("serializable+ro", opts[3].String())
+	assert.Equal(&sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, opts[3].TxOptions())
+
+	opts, err = parseTxOptions("snapshot", "")
+	require.NoError(err)
+	assert.Equal("snapshot", opts[0].String())
+	assert.Equal(&sql.TxOptions{Isolation: sql.LevelRepeatableRead}, opts[0].TxOptions())
+	_, err = parseTxOptions("linearizable", "")
+	require.Error(err)
+	_, err = parseTxOptions("", "maybe")
+	require.Error(err)
+}
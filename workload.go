@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// workloadType is what every iteration of a run case loads
+type workloadType string
+
+// supported workloads
+const (
+	// workloadForums load the first forums or a page of forums in no specific order
+	workloadForums workloadType = "forums"
+	// workloadLatest load forums ordered by newest thread with newest threads and posts
+	workloadLatest workloadType = "latest"
+	// workloadLookup load one forum by ID with newest threads and posts
+	workloadLookup workloadType = "lookup"
+	// workloadSearch load forums with threads and posts matching a full-text search term
+	workloadSearch workloadType = "search"
+)
+
+var workloadTypes = []workloadType{workloadForums, workloadLatest, workloadLookup, workloadSearch}
+
+func parseWorkload(name string) (workloadType, error) {
+	for _, workload := range workloadTypes {
+		if string(workload) == name {
+			return workload, nil
+		}
+	}
+	return "", fmt.Errorf("unknown workload %q", name)
+}
+
+// strategies return all strategies of workload of dialect
+func (t workloadType) strategies(dialect forumdb.Dialect) []forumdb.Strategy {
+	switch t {
+	case workloadLatest:
+		return forumdb.DialectLatestStrategies(dialect)
+	case workloadLookup:
+		return forumdb.DialectLookupStrategies(dialect)
+	case workloadSearch:
+		return forumdb.DialectSearchStrategies(dialect)
+	}
+	return forumdb.DialectStrategies(dialect)
+}
+
+// indexes return indexes workload needs besides create_table.sql
+func (t workloadType) indexes() []forumdb.Index {
+	switch t {
+	case workloadLatest:
+		return forumdb.LatestIndexes
+	case workloadSearch:
+		return forumdb.SearchIndexes
+	}
+	return nil
+}
+
+// picks return true when every iteration of workload picks a forum ID or term by a lookup distribution
+func (t workloadType) picks() bool {
+	return t == workloadLookup || t == workloadSearch
+}
+
+// warnMissingIndexes log indexes of workload not existing on target
+func warnMissingIndexes(ctx context.Context, target *targetType, workload workloadType, logger loggerType) (err error) {
+	for _, index := range forumdb.DialectIndexes(workload.indexes(), target.Dialect) {
+		exists, err := index.Exists(ctx, target.DB, target.Dialect)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			logger.Logf("index %s is missing, %s workload is slow without it, create it by the index command\n", index.Name, workload)
+		}
+	}
+	return
+}
+
+// checkCounters return error when counter columns are missing on target,
+// they are read by the counter aggregate and updated by every write of writers
+func checkCounters(ctx context.Context, target *targetType, aggregates []forumdb.Aggregate, writers int) (err error) {
+	needed := writers > 0
+	for _, aggregate := range aggregates {
+		needed = needed || aggregate == forumdb.AggregateCounter
+	}
+	if !needed {
+		return
+	}
+	for _, counter := range forumdb.Counters {
+		exists, err := counter.Exists(ctx, target.DB, target.Dialect)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%s: counter column %s is missing, create it by the index or seed command", target.Label(), counter)
+		}
+	}
+	return
+}
+
+func runIndex(ctx context.Context, args []string) (err error) {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+
+	targets := loadAllTargets()
+	if len(targets) < 1 {
+		return errors.New("no target defined, set MYSQL_URL or PGSQL_URL")
+	}
+	for _, target := range targets {
+		if err = createTargetIndexes(ctx, target); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// createTargetIndexes create indexes of every workload and counter columns not existing on target,
+// created counters are filled with current counts
+func createTargetIndexes(ctx context.Context, target *targetType) (err error) {
+	if err = target.Open(ctx); err != nil {
+		return
+	}
+	defer func() {
+		if errClose := target.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+
+	for _, workload := range workloadTypes {
+		created, err := forumdb.CreateIndexes(ctx, target.DB, target.Dialect, workload.indexes())
+		for _, index := range created {
+			consoleLogger.Logf("%s: created index %s\n", target.Label(), index)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", target.Label(), err)
+		}
+	}
+
+	created, err := forumdb.CreateCounters(ctx, target.DB, target.Dialect, forumdb.Counters)
+	if err != nil {
+		return fmt.Errorf("%s: %v", target.Label(), err)
+	}
+	if len(created) < 1 {
+		return
+	}
+	if err = forumdb.RefreshCounters(ctx, target.DB, created); err != nil {
+		return fmt.Errorf("%s: %v", target.Label(), err)
+	}
+	for _, counter := range created {
+		consoleLogger.Logf("%s: created counter %s\n", target.Label(), counter)
+	}
+	return
+}
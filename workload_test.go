@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+func Test_workloadType(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	workload, err := parseWorkload("latest")
+	require.NoError(err)
+	assert.Equal(workloadLatest, workload)
+	_, err = parseWorkload("hot")
+	assert.Error(err)
+
+	for _, workload := range workloadTypes {
+		strategies := workload.strategies(forumdb.PGSQL)
+		require.NotEmpty(strategies, workload)
+		assert.Equal("app-query", strategies[0].Name, workload)
+	}
+	assert.NotEqual(workloadLatest.strategies(forumdb.MySQL)[0].Queries, workloadForums.strategies(forumdb.MySQL)[0].Queries)
+	assert.Equal(forumdb.LatestIndexes, workloadLatest.indexes())
+	assert.Empty(workloadForums.indexes())
+
+	runCase := runCaseType{Workload: workloadLatest, Strategy: workloadLatest.strategies(forumdb.MySQL)[1], TxOption: txOptionType{Isolation: "default"}, Limit: forumdb.DefaultLimit}
+	assert.Equal("latest sub-query default 10x10x10", runCase.String())
+
+	assert.Equal(forumdb.SearchIndexes, workloadSearch.indexes())
+	assert.True(workloadSearch.picks())
+	assert.False(workloadLatest.picks())
+	terms := drawSearchTerms(searchTermCount)
+	require.Len(terms, searchTermCount)
+	assert.Equal(terms, drawSearchTerms(searchTermCount))
+	assert.True(sort.StringsAreSorted(terms))
+	for i, term := range terms {
+		assert.True(len(term) >= 4 && len(term) < 12, term)
+		if i > 0 {
+			assert.NotEqual(terms[i-1], term)
+		}
+	}
+	target := &targetType{ForumIDs: []string{"f0"}, SearchTerms: terms}
+	runCase = runCaseType{Workload: workloadSearch, Lookup: lookupFixed, Limit: forumdb.DefaultLimit}
+	request := runCase.request(nil, runCase.picker(target, 1))
+	assert.Equal(terms[0], request.Term)
+	assert.Empty(request.ForumID)
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drhodes/golorem"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tsaikd/go-db-benchmark-app-sub-query/forumdb"
+)
+
+// writeKindType is one kind of write operation of the write load
+type writeKindType string
+
+// supported write kinds
+const (
+	// writePost insert a post into an existing thread
+	writePost writeKindType = "post"
+	// writeThread insert a thread without posts into an existing forum
+	writeThread writeKindType = "thread"
+	// writeEdit update name and lorem of an existing post, the original content is restored after the case,
+	// a post is inserted instead when the writer has no post to edit
+	writeEdit writeKindType = "edit"
+	// writeDelete delete a post or thread inserted by the same writer, a post is inserted instead when there is none
+	writeDelete writeKindType = "delete"
+)
+
+var writeKinds = []writeKindType{writePost, writeThread, writeEdit, writeDelete}
+
+// writeMixType is the relative weight of every write kind
+type writeMixType map[writeKindType]int
+
+// defaultWriteMix is mostly new posts, like a forum
+const defaultWriteMix = "post=70,thread=10,edit=15,delete=5"
+
+// parseWriteMix parse comma separated KIND=WEIGHT, e.g. post=70,thread=10,edit=15,delete=5
+func parseWriteMix(value string) (mix writeMixType, err error) {
+	mix = writeMixType{}
+	total := 0
+	for _, item := range splitNames(value) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid write mix %q, should be KIND=WEIGHT", item)
+		}
+		kind := writeKindType(strings.TrimSpace(kv[0]))
+		if !containsWriteKind(kind) {
+			return nil, fmt.Errorf("unknown write kind %q", kind)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid write weight %q: %v", item, err)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("invalid write weight %q, should not be negative", item)
+		}
+		mix[kind] = weight
+		total += weight
+	}
+	if total < 1 {
+		return nil, fmt.Errorf("write mix %q has no positive weight", value)
+	}
+	return
+}
+
+func containsWriteKind(kind writeKindType) bool {
+	for _, k := range writeKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (t writeMixType) String() string {
+	items := []string{}
+	for _, kind := range writeKinds {
+		if weight := t[kind]; weight > 0 {
+			items = append(items, fmt.Sprintf("%s=%d", kind, weight))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// pick return a random kind by weight
+func (t writeMixType) pick(random *rand.Rand) writeKindType {
+	total := 0
+	for _, kind := range writeKinds {
+		total += t[kind]
+	}
+	n := random.Intn(total)
+	for _, kind := range writeKinds {
+		if n < t[kind] {
+			return kind
+		}
+		n -= t[kind]
+	}
+	return writePost
+}
+
+// writeConfigType control writers running alongside the read strategies
+type writeConfigType struct {
+	// Writers is the concurrent writer count, no write load when 0
+	Writers int
+	// Rate is the total write operations per second of all writers, as fast as possible when 0
+	Rate float64
+	Mix  writeMixType
+}
+
+func (t writeConfigType) String() string {
+	return fmt.Sprintf("writers=%d rate=%g mix=%s", t.Writers, t.Rate, t.Mix)
+}
+
+// writeTargetLimit cap rows loaded as write targets of each table
+const writeTargetLimit = 100000
+
+// writeTargetsType are existing rows writes pick from
+type writeTargetsType struct {
+	ForumIDs  []string
+	ThreadIDs []string
+	PostIDs   []string
+}
+
+// loadWriteTargets return at most writeTargetLimit IDs of each table of target
+func loadWriteTargets(ctx context.Context, target *targetType) (targets writeTargetsType, err error) {
+	db := target.DB
+	if err = sqlx.SelectContext(ctx, db, &targets.ForumIDs, db.Rebind(`SELECT forumID FROM forums LIMIT ?;`), writeTargetLimit); err != nil {
+		return
+	}
+	if err = sqlx.SelectContext(ctx, db, &targets.ThreadIDs, db.Rebind(`SELECT threadID FROM threads LIMIT ?;`), writeTargetLimit); err != nil {
+		return
+	}
+	if err = sqlx.SelectContext(ctx, db, &targets.PostIDs, db.Rebind(`SELECT postID FROM posts LIMIT ?;`), writeTargetLimit); err != nil {
+		return
+	}
+	if len(targets.ForumIDs) < 1 || len(targets.ThreadIDs) < 1 || len(targets.PostIDs) < 1 {
+		err = fmt.Errorf("%s needs forums, threads and posts to write", target.Label())
+	}
+	return
+}
+
+// writeResultType is the write load measured while a run case was reading
+type writeResultType struct {
+	writeConfigType
+	Samples []time.Duration
+	Elapsed time.Duration
+	// Ops is the successful operation count of every kind
+	Ops    map[writeKindType]int
+	Errors int
+}
+
+// Latency return latency distribution of successful writes
+func (t writeResultType) Latency() latencyType {
+	return newLatency(t.Samples)
+}
+
+// Throughput return successful writes per second
+func (t writeResultType) Throughput() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(len(t.Samples)) / t.Elapsed.Seconds()
+}
+
+func (t writeResultType) String() string {
+	latency := t.Latency()
+	ops := []string{}
+	for _, kind := range writeKinds {
+		if count := t.Ops[kind]; count > 0 {
+			ops = append(ops, fmt.Sprintf("%s=%d", kind, count))
+		}
+	}
+	return fmt.Sprintf("writers=%d writes=%d ops/s=%.2f p50=%v p99=%v errors=%d %s",
+		t.Writers, len(t.Samples), t.Throughput(), latency.P50, latency.P99, t.Errors, strings.Join(ops, " "))
+}
+
+// writtenRowType is a row inserted by a writer, ParentID is the forum of a thread or the thread of a post
+type writtenRowType struct {
+	ParentID string
+	ID       string
+}
+
+// postContentType is the editable content of a post
+type postContentType struct {
+	Name  string `db:"name"`
+	Lorem string `db:"lorem"`
+}
+
+// writerType is one writer of the write load, it keeps rows it inserted to delete them
+// and the original content of posts it edited to restore them
+type writerType struct {
+	db      *sqlx.DB
+	targets writeTargetsType
+	mix     writeMixType
+	random  *rand.Rand
+	// interval between writes of this writer, no pause when 0
+	interval time.Duration
+	// editIDs are existing posts only this writer edits
+	editIDs []string
+
+	threads []writtenRowType
+	posts   []writtenRowType
+	// edited keep the original content of every edited post
+	edited map[string]postContentType
+	// editID is the post of the next edit, its original content is read before the edit is timed
+	editID string
+
+	samples []time.Duration
+	ops     map[writeKindType]int
+	errors  int
+}
+
+// run write until ctx done, failed writes are counted and skipped
+func (t *writerType) run(ctx context.Context) error {
+	next := time.Now()
+	for {
+		if t.interval > 0 {
+			next = next.Add(t.interval)
+			if wait := time.Until(next); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(wait):
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		kind := t.mix.pick(t.random)
+		if kind == writeEdit {
+			if err := t.prepareEdit(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				t.errors++
+				continue
+			}
+		}
+		start := time.Now()
+		kind, err := t.write(ctx, kind)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			t.errors++
+			continue
+		}
+		t.samples = append(t.samples, time.Since(start))
+		t.ops[kind]++
+	}
+}
+
+// write run one write of kind, an insert or delete runs in a transaction with its counter update,
+// return the kind actually written
+func (t *writerType) write(ctx context.Context, kind writeKindType) (writeKindType, error) {
+	switch kind {
+	case writeThread:
+		thread := writtenRowType{ParentID: t.targets.ForumIDs[t.random.Intn(len(t.targets.ForumIDs))], ID: uuid.New().String()}
+		if err := inTx(ctx, t.db, func(tx *sqlx.Tx) error {
+			return insertThread(ctx, tx, thread.ParentID, thread.ID, lorem.Sentence(3, 10), lorem.Sentence(50, 100))
+		}); err != nil {
+			return kind, err
+		}
+		t.threads = append(t.threads, thread)
+		return kind, nil
+	case writeEdit:
+		if t.editID != "" {
+			err := t.updatePost(ctx, t.editID, postContentType{Name: lorem.Sentence(3, 10), Lorem: lorem.Sentence(50, 200)})
+			return kind, err
+		}
+	case writeDelete:
+		if len(t.posts) > 0 {
+			if err := t.deletePost(ctx, t.posts[0]); err != nil {
+				return kind, err
+			}
+			t.posts = t.posts[1:]
+			return kind, nil
+		}
+		if len(t.threads) > 0 {
+			if err := t.deleteThread(ctx, t.threads[0]); err != nil {
+				return kind, err
+			}
+			t.threads = t.threads[1:]
+			return kind, nil
+		}
+	}
+
+	post := writtenRowType{ParentID: t.targets.ThreadIDs[t.random.Intn(len(t.targets.ThreadIDs))], ID: uuid.New().String()}
+	if err := inTx(ctx, t.db, func(tx *sqlx.Tx) error {
+		return insertPost(ctx, tx, post.ParentID, post.ID, lorem.Sentence(3, 10), lorem.Sentence(50, 200))
+	}); err != nil {
+		return writePost, err
+	}
+	t.posts = append(t.posts, post)
+	return writePost, nil
+}
+
+// prepareEdit pick the post of the next edit and keep its original content when it was never edited,
+// no post is picked when writer has no post to edit
+func (t *writerType) prepareEdit(ctx context.Context) (err error) {
+	t.editID = ""
+	if len(t.editIDs) < 1 {
+		return
+	}
+	postID := t.editIDs[t.random.Intn(len(t.editIDs))]
+	if _, ok := t.edited[postID]; !ok {
+		content := postContentType{}
+		if err = sqlx.GetContext(ctx, t.db, &content, t.db.Rebind(`SELECT name, lorem FROM posts WHERE postID = ?;`), postID); err != nil {
+			return
+		}
+		t.edited[postID] = content
+	}
+	t.editID = postID
+	return
+}
+
+func (t *writerType) updatePost(ctx context.Context, postID string, content postContentType) (err error) {
+	_, err = t.db.ExecContext(ctx, t.db.Rebind(`UPDATE posts SET name = ?, lorem = ? WHERE postID = ?;`), content.Name, content.Lorem, postID)
+	return
+}
+
+func (t *writerType) deletePost(ctx context.Context, post writtenRowType) error {
+	return inTx(ctx, t.db, func(tx *sqlx.Tx) error {
+		return deletePost(ctx, tx, post.ParentID, post.ID)
+	})
+}
+
+func (t *writerType) deleteThread(ctx context.Context, thread writtenRowType) error {
+	return inTx(ctx, t.db, func(tx *sqlx.Tx) error {
+		return deleteThread(ctx, tx, thread.ParentID, thread.ID)
+	})
+}
+
+// clean delete rows inserted by writer and restore edited posts, posts of inserted threads are deleted by cascade
+func (t *writerType) clean(ctx context.Context) (err error) {
+	for _, post := range t.posts {
+		if err = t.deletePost(ctx, post); err != nil {
+			return
+		}
+	}
+	t.posts = nil
+	for _, thread := range t.threads {
+		if err = t.deleteThread(ctx, thread); err != nil {
+			return
+		}
+	}
+	t.threads = nil
+	for postID, content := range t.edited {
+		if err = t.updatePost(ctx, postID, content); err != nil {
+			return
+		}
+		delete(t.edited, postID)
+	}
+	return
+}
+
+// writeLoadType is the running writers of one run case
+type writeLoadType struct {
+	config  writeConfigType
+	writers []*writerType
+	start   time.Time
+	cancel  context.CancelFunc
+	eg      *errgroup.Group
+}
+
+// startWriteLoad start writers of config on db, nil when config has no writer
+func startWriteLoad(ctx context.Context, db *sqlx.DB, config writeConfigType, targets writeTargetsType) *writeLoadType {
+	if config.Writers < 1 {
+		return nil
+	}
+	writeCtx, cancel := context.WithCancel(ctx)
+	eg, egCtx := errgroup.WithContext(writeCtx)
+	load := &writeLoadType{
+		config: config,
+		start:  time.Now(),
+		cancel: cancel,
+		eg:     eg,
+	}
+	interval := time.Duration(0)
+	if config.Rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(config.Writers) / config.Rate)
+	}
+	for w := 0; w < config.Writers; w++ {
+		writer := &writerType{
+			db:       db,
+			targets:  targets,
+			mix:      config.Mix,
+			random:   rand.New(rand.NewSource(int64(w + 1))),
+			interval: interval,
+			edited:   map[string]postContentType{},
+			ops:      map[writeKindType]int{},
+		}
+		// writers edit disjoint posts, so every writer restores the content it saw first
+		for i := w; i < len(targets.PostIDs); i += config.Writers {
+			writer.editIDs = append(writer.editIDs, targets.PostIDs[i])
+		}
+		load.writers = append(load.writers, writer)
+		eg.Go(func() error {
+			return writer.run(egCtx)
+		})
+	}
+	return load
+}
+
+// Stop stop writers, delete rows they inserted and return the measured write load
+func (t *writeLoadType) Stop(ctx context.Context) (result writeResultType, err error) {
+	if t == nil {
+		return
+	}
+	t.cancel()
+	if err = t.eg.Wait(); err != nil {
+		return
+	}
+	result = writeResultType{
+		writeConfigType: t.config,
+		Elapsed:         time.Since(t.start),
+		Ops:             map[writeKindType]int{},
+	}
+	for _, writer := range t.writers {
+		result.Samples = append(result.Samples, writer.samples...)
+		result.Errors += writer.errors
+		for kind, count := range writer.ops {
+			result.Ops[kind] += count
+		}
+		if err = writer.clean(ctx); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeLoadRequestType is a request bench sends to a write-load process, one JSON value per request
+type writeLoadRequestType struct {
+	// Command is open, start or stop
+	Command string
+	// Dialect, URL and Config are the target and writers of open
+	Dialect forumdb.Dialect `json:",omitempty"`
+	URL     string          `json:",omitempty"`
+	Config  writeConfigType
+}
+
+// writeLoadReplyType is the reply of a write-load process to every request, Result is the write load of stop
+type writeLoadReplyType struct {
+	Error  string           `json:",omitempty"`
+	Result *writeResultType `json:",omitempty"`
+}
+
+// runWriteLoad serve requests of bench on stdin until EOF, replies are written to stdout
+func runWriteLoad(ctx context.Context, args []string) (err error) {
+	return serveWriteLoad(ctx, json.NewDecoder(os.Stdin), json.NewEncoder(os.Stdout))
+}
+
+// serveWriteLoad open target on open, start writers on start, stop them on stop and reply every request,
+// failed requests are replied with Error
+func serveWriteLoad(ctx context.Context, requests *json.Decoder, replies *json.Encoder) (err error) {
+	target := (*targetType)(nil)
+	config := writeConfigType{}
+	targets := writeTargetsType{}
+	load := (*writeLoadType)(nil)
+	defer func() {
+		if target == nil {
+			return
+		}
+		if errClose := target.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+
+	for {
+		request := writeLoadRequestType{}
+		if err = requests.Decode(&request); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+
+		reply := writeLoadReplyType{}
+		errRequest := error(nil)
+		switch {
+		case request.Command == "open" && target == nil:
+			opened := &targetType{Name: "writers", Dialect: request.Dialect, URL: request.URL}
+			if errRequest = opened.Open(ctx); errRequest != nil {
+				opened.Close()
+				break
+			}
+			target, config = opened, request.Config
+			targets, errRequest = loadWriteTargets(ctx, target)
+		case request.Command == "start" && target != nil && load == nil:
+			load = startWriteLoad(ctx, target.DB, config, targets)
+		case request.Command == "stop" && load != nil:
+			result, errStop := load.Stop(ctx)
+			load, errRequest = nil, errStop
+			reply.Result = &result
+		default:
+			errRequest = fmt.Errorf("unexpected write-load command %q", request.Command)
+		}
+		if errRequest != nil {
+			reply.Error = errRequest.Error()
+		}
+		if err = replies.Encode(reply); err != nil {
+			return
+		}
+	}
+}
+
+// writeProcessType is a write-load process of bench writing one target,
+// writers have their own connection pool, heap and GC, so reads measured by bench don't count them
+type writeProcessType struct {
+	cmd      *exec.Cmd
+	requests io.WriteCloser
+	encoder  *json.Encoder
+	replies  *json.Decoder
+}
+
+// startWriteProcess start a write-load process of this executable with writers of config on target,
+// writers connect to target directly, not through the latency proxy
+func startWriteProcess(ctx context.Context, target *targetType, config writeConfigType) (process *writeProcessType, err error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.CommandContext(ctx, executable, "write-load")
+	cmd.Stderr = os.Stderr
+	requests, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	replies, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		return
+	}
+	process = &writeProcessType{
+		cmd:      cmd,
+		requests: requests,
+		encoder:  json.NewEncoder(requests),
+		replies:  json.NewDecoder(replies),
+	}
+	if _, err = process.call(writeLoadRequestType{Command: "open", Dialect: target.Dialect, URL: target.URL, Config: config}); err != nil {
+		process.Close()
+		return nil, fmt.Errorf("%s writers: %v", target.Label(), err)
+	}
+	return
+}
+
+func (t *writeProcessType) call(request writeLoadRequestType) (reply writeLoadReplyType, err error) {
+	if err = t.encoder.Encode(request); err != nil {
+		return
+	}
+	if err = t.replies.Decode(&reply); err != nil {
+		return
+	}
+	if reply.Error != "" {
+		err = errors.New(reply.Error)
+	}
+	return
+}
+
+// Start start writers
+func (t *writeProcessType) Start() (err error) {
+	_, err = t.call(writeLoadRequestType{Command: "start"})
+	return
+}
+
+// Stop stop writers, delete rows they inserted, restore posts they edited and return the measured write load
+func (t *writeProcessType) Stop() (result writeResultType, err error) {
+	reply, err := t.call(writeLoadRequestType{Command: "stop"})
+	if reply.Result != nil {
+		result = *reply.Result
+	}
+	return
+}
+
+// Close end the process after it closes its target
+func (t *writeProcessType) Close() error {
+	t.requests.Close()
+	return t.cmd.Wait()
+}
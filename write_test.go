@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseWriteMix(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	mix, err := parseWriteMix(defaultWriteMix)
+	require.NoError(err)
+	assert.Equal(defaultWriteMix, mix.String())
+
+	mix, err = parseWriteMix("delete=1, post=3, edit=0")
+	require.NoError(err)
+	assert.Equal("post=3,delete=1", mix.String())
+
+	counts := map[writeKindType]int{}
+	random := rand.New(rand.NewSource(1))
+	for n := 0; n < 4000; n++ {
+		counts[mix.pick(random)]++
+	}
+	assert.Zero(counts[writeEdit])
+	assert.Zero(counts[writeThread])
+	assert.InDelta(3000, counts[writePost], 200)
+	assert.InDelta(1000, counts[writeDelete], 200)
+
+	for _, value := range []string{"", "post", "post=x", "post=-1", "move=1", "post=0,edit=0"} {
+		_, err = parseWriteMix(value)
+		assert.Error(err, value)
+	}
+}
+
+func Test_writeResult(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	writes := &writeResultType{
+		writeConfigType: writeConfigType{Writers: 2, Rate: 100, Mix: writeMixType{writePost: 1}},
+		Samples:         []time.Duration{time.Millisecond, 3 * time.Millisecond},
+		Elapsed:         time.Second,
+		Ops:             map[writeKindType]int{writePost: 2},
+		Errors:          1,
+	}
+	assert.Equal(2.0, writes.Throughput())
+	assert.Contains(writes.String(), "writers=2 writes=2")
+
+	record := newWriteRecord(writes)
+	require.NotNil(record)
+	assert.Equal("post=1", record.Mix)
+	assert.Equal(map[string]int{"post": 2}, record.Ops)
+	assert.Nil(newWriteRecord(nil))
+}
+
+func Test_serveWriteLoad(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(assert)
+	require := require.New(t)
+	require.NotNil(require)
+
+	requests := &bytes.Buffer{}
+	encoder := json.NewEncoder(requests)
+	for _, command := range []string{"start", "stop", "open"} {
+		require.NoError(encoder.Encode(writeLoadRequestType{Command: command}))
+	}
+	replies := &bytes.Buffer{}
+	require.NoError(serveWriteLoad(context.Background(), json.NewDecoder(requests), json.NewEncoder(replies)))
+
+	decoder := json.NewDecoder(replies)
+	for _, expected := range []string{
+		`unexpected write-load command "start"`,
+		`unexpected write-load command "stop"`,
+		`unknown dialect "" of target "writers"`,
+	} {
+		reply := writeLoadReplyType{}
+		require.NoError(decoder.Decode(&reply))
+		assert.Equal(expected, reply.Error)
+		assert.Nil(reply.Result)
+	}
+	assert.False(decoder.More())
+}